@@ -0,0 +1,304 @@
+// Package client provides a Go API for controlling a running Scythix daemon
+// over its control socket.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"scythix/playlist"
+	"scythix/protocol"
+)
+
+// Network is the network type of the daemon's control socket.
+const Network = "unix"
+
+// VersionError reports that the running daemon speaks a protocol version
+// incompatible with the client.
+type VersionError struct {
+	// Daemon is the protocol version of the daemon. It is zero if the daemon
+	// predates version negotiation.
+	Daemon int
+	// DaemonVersion is the release version of the daemon, if known.
+	DaemonVersion string
+}
+
+func (e *VersionError) Error() string {
+	switch {
+	case e.Daemon == 0:
+		return fmt.Sprintf("the running daemon is older than this client (protocol v%d), "+
+			"stop it and start playback again", protocol.Version)
+	case e.Daemon < protocol.Version:
+		return fmt.Sprintf("the running daemon (scythix %s, protocol v%d) is older than this client (protocol v%d), "+
+			"stop it and start playback again", e.DaemonVersion, e.Daemon, protocol.Version)
+	default:
+		return fmt.Sprintf("the running daemon (scythix %s, protocol v%d) is newer than this client (protocol v%d), "+
+			"upgrade the client", e.DaemonVersion, e.Daemon, protocol.Version)
+	}
+}
+
+// Client is a connection to a Scythix daemon.
+// Every method accepts a context that limits how long the call may take.
+type Client struct {
+	rpc   *rpc.Client
+	hello protocol.HelloReply
+}
+
+// Dial connects to the daemon listening on the given Unix socket and checks
+// that it speaks the same protocol version. A *VersionError is returned if the
+// versions are incompatible.
+func Dial(ctx context.Context, socketPath string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, Network, socketPath)
+	if err != nil {
+		// Daemons predating version negotiation listen on a packet socket.
+		if errors.Is(err, syscall.EPROTOTYPE) {
+			return nil, &VersionError{}
+		}
+		return nil, err
+	}
+
+	c := &Client{rpc: rpc.NewClient(conn)}
+	args := &protocol.HelloArgs{ProtocolVersion: protocol.Version}
+	if err := c.call(ctx, protocol.MethodHello, args, &c.hello); err != nil {
+		c.Close()
+		var srvErr rpc.ServerError
+		if errors.As(err, &srvErr) && strings.HasPrefix(string(srvErr), "rpc: can't find") {
+			return nil, &VersionError{}
+		}
+		return nil, err
+	}
+
+	if c.hello.ProtocolVersion != protocol.Version {
+		c.Close()
+		return nil, &VersionError{Daemon: c.hello.ProtocolVersion, DaemonVersion: c.hello.Version}
+	}
+
+	return c, nil
+}
+
+// Server returns the daemon description received while connecting.
+func (c *Client) Server() protocol.HelloReply {
+	return c.hello
+}
+
+// HasFeature reports whether the daemon has the given protocol.Feature* capability enabled.
+func (c *Client) HasFeature(feature string) bool {
+	return slices.Contains(c.hello.Features, feature)
+}
+
+// Close closes the connection to the daemon.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// Pause toggles the paused state of the player.
+func (c *Client) Pause(ctx context.Context) error {
+	return c.call(ctx, protocol.MethodPause, &protocol.Empty{}, &protocol.Empty{})
+}
+
+// Stop stops playback and terminates the daemon.
+func (c *Client) Stop(ctx context.Context) error {
+	return c.call(ctx, protocol.MethodStop, &protocol.Empty{}, &protocol.Empty{})
+}
+
+// Next skips to the next track.
+func (c *Client) Next(ctx context.Context) error {
+	return c.call(ctx, protocol.MethodNext, &protocol.Empty{}, &protocol.Empty{})
+}
+
+// Rewind rewinds to the previous track.
+func (c *Client) Rewind(ctx context.Context) error {
+	return c.call(ctx, protocol.MethodRewind, &protocol.Empty{}, &protocol.Empty{})
+}
+
+// Mute toggles the mute state of the player.
+func (c *Client) Mute(ctx context.Context) error {
+	return c.call(ctx, protocol.MethodMute, &protocol.Empty{}, &protocol.Empty{})
+}
+
+// TurnUp increases the volume by one step and returns the resulting level.
+func (c *Client) TurnUp(ctx context.Context) (float64, error) {
+	var reply protocol.VolumeReply
+	err := c.call(ctx, protocol.MethodTurnUp, &protocol.Empty{}, &reply)
+	return reply.Level, err
+}
+
+// TurnDown decreases the volume by one step and returns the resulting level.
+func (c *Client) TurnDown(ctx context.Context) (float64, error) {
+	var reply protocol.VolumeReply
+	err := c.call(ctx, protocol.MethodTurnDown, &protocol.Empty{}, &reply)
+	return reply.Level, err
+}
+
+// SetVolume sets the volume to the given level and returns the level actually set.
+func (c *Client) SetVolume(ctx context.Context, level int) (float64, error) {
+	var reply protocol.VolumeReply
+	err := c.call(ctx, protocol.MethodSetVol, &protocol.SetVolArgs{Level: level}, &reply)
+	return reply.Level, err
+}
+
+// Queue adds an audio file or an M3U playlist to the end of the queue.
+// The path must be absolute, since it is resolved by the daemon.
+func (c *Client) Queue(ctx context.Context, path string) error {
+	return c.call(ctx, protocol.MethodQueue, &protocol.QueueArgs{Path: path}, &protocol.Empty{})
+}
+
+// TrackInfo returns the metadata of the current track.
+func (c *Client) TrackInfo(ctx context.Context) (*playlist.AudioProperties, error) {
+	var prop playlist.AudioProperties
+	if err := c.call(ctx, protocol.MethodTrackInfo, &protocol.Empty{}, &prop); err != nil {
+		return nil, err
+	}
+	return &prop, nil
+}
+
+// PlaylistInfo returns the queue formatted for display.
+func (c *Client) PlaylistInfo(ctx context.Context) (string, error) {
+	var reply protocol.PlaylistInfoReply
+	err := c.call(ctx, protocol.MethodPlaylistInfo, &protocol.Empty{}, &reply)
+	return reply.Text, err
+}
+
+// SavePlaylist saves the queue as an M3U playlist in the given directory and
+// returns the path of the created file. The "-" directory stands for the
+// playlist directory from the daemon's configuration.
+func (c *Client) SavePlaylist(ctx context.Context, dir string) (string, error) {
+	var reply protocol.SavePlaylistReply
+	err := c.call(ctx, protocol.MethodSavePlaylist, &protocol.SavePlaylistArgs{Dir: dir}, &reply)
+	return reply.Path, err
+}
+
+// Status returns the current state of the player.
+func (c *Client) Status(ctx context.Context) (*protocol.Status, error) {
+	var st protocol.Status
+	if err := c.call(ctx, protocol.MethodStatus, &protocol.Empty{}, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Tracks returns the entries of the playback queue.
+func (c *Client) Tracks(ctx context.Context) (*protocol.TracksReply, error) {
+	var reply protocol.TracksReply
+	if err := c.call(ctx, protocol.MethodTracks, &protocol.Empty{}, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// Cover returns the artwork of the current track. protocol.ErrNoCover is returned
+// if nothing is playing or the track has no cover art.
+func (c *Client) Cover(ctx context.Context) (*protocol.CoverReply, error) {
+	var reply protocol.CoverReply
+	if err := c.call(ctx, protocol.MethodCover, &protocol.Empty{}, &reply); err != nil {
+		return nil, err
+	}
+	if len(reply.Data) == 0 {
+		return nil, protocol.ErrNoCover
+	}
+	return &reply, nil
+}
+
+// Lyrics returns the lyrics of the current track. protocol.ErrNoLyrics is returned
+// if nothing is playing or the track has no lyrics.
+func (c *Client) Lyrics(ctx context.Context) (*protocol.LyricsReply, error) {
+	var reply protocol.LyricsReply
+	if err := c.call(ctx, protocol.MethodLyrics, &protocol.Empty{}, &reply); err != nil {
+		return nil, err
+	}
+	if len(reply.Lines) == 0 {
+		return &reply, protocol.ErrNoLyrics
+	}
+	return &reply, nil
+}
+
+// ReloadTags makes the daemon read the tags of the audio file again, if it is
+// queued. It returns the number of queue entries updated.
+func (c *Client) ReloadTags(ctx context.Context, path string) (int, error) {
+	var reply protocol.ReloadTagsReply
+	err := c.call(ctx, protocol.MethodReloadTags, &protocol.ReloadTagsArgs{Path: path}, &reply)
+	return reply.Updated, err
+}
+
+// Jump starts playing the track at the given position of the queue, starting at 1.
+func (c *Client) Jump(ctx context.Context, index int) error {
+	return c.call(ctx, protocol.MethodJump, &protocol.JumpArgs{Index: index}, &protocol.Empty{})
+}
+
+// Remove removes the track at the given position from the queue.
+func (c *Client) Remove(ctx context.Context, index int) error {
+	return c.call(ctx, protocol.MethodRemove, &protocol.RemoveArgs{Index: index}, &protocol.Empty{})
+}
+
+// Move moves the track at one position of the queue to another.
+func (c *Client) Move(ctx context.Context, from, to int) error {
+	return c.call(ctx, protocol.MethodMove, &protocol.MoveArgs{From: from, To: to}, &protocol.Empty{})
+}
+
+// Seek changes the playback position of the current track. If relative is set,
+// the position is an offset from the current position.
+func (c *Client) Seek(ctx context.Context, position time.Duration, relative bool) error {
+	args := &protocol.SeekArgs{Position: position, Relative: relative}
+	return c.call(ctx, protocol.MethodSeek, args, &protocol.Empty{})
+}
+
+// ConfigGet returns the value of a config key used by the daemon.
+func (c *Client) ConfigGet(ctx context.Context, key string) (string, error) {
+	var reply protocol.ConfigGetReply
+	err := c.call(ctx, protocol.MethodConfigGet, &protocol.ConfigGetArgs{Key: key}, &reply)
+	return reply.Value, err
+}
+
+// ConfigSet changes a config key of the daemon, which applies it immediately
+// and saves it to the config file.
+func (c *Client) ConfigSet(ctx context.Context, key, value string) error {
+	return c.call(ctx, protocol.MethodConfigSet, &protocol.ConfigSetArgs{Key: key, Value: value}, &protocol.Empty{})
+}
+
+// Subscribe calls fn for every event published by the daemon until the context
+// is cancelled, fn returns an error or the daemon stops. It returns nil if the
+// daemon stopped or the connection was shut down.
+func (c *Client) Subscribe(ctx context.Context, fn func(protocol.Event) error) error {
+	var since uint64
+	for {
+		var ev protocol.Event
+		err := c.call(ctx, protocol.MethodWaitEvent, &protocol.WaitEventArgs{Since: since}, &ev)
+		if err != nil {
+			if errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+
+		if ev.Kind == "" {
+			continue
+		}
+		since = ev.Seq
+		if err := fn(ev); err != nil {
+			return err
+		}
+		if ev.Kind == protocol.EventStop {
+			return nil
+		}
+	}
+}
+
+// call invokes the named method and waits for its completion or the context cancellation.
+func (c *Client) call(ctx context.Context, method string, args any, reply any) error {
+	call := c.rpc.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.Done:
+		return call.Error
+	}
+}
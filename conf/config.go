@@ -3,6 +3,7 @@
 package conf
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path"
@@ -13,14 +14,28 @@ import (
 )
 
 const (
-	defaultConfPath = ".config/scythix"
-	confFileName    = "conf.toml"
+	confFileName = "conf.toml"
+
+	// PathEnv is the environment variable overriding the location of the config file.
+	PathEnv = "SCYTHIX_CONFIG"
 
 	defaultLogLevel = "debug"
 
-	defaultVolLevel    = 16
-	defaultSampleRate  = 44100
-	defaultPlaylistDir = "Scythix/"
+	defaultVolLevel   = 16
+	defaultSampleRate = 44100
+	// legacyPlaylistDir is the default playlist_dir of config versions before 3.
+	legacyPlaylistDir = "Scythix/"
+
+	DefaultNowPlayingTemplate = "%artist% – %title%"
+
+	DefaultInfoFormat = "%filename%\n" +
+		"Title        | %title%\nArtist       | %artist%\nAlbum        | %album%\n" +
+		"Album artist | %album_artist%\nComposer     | %composer%\nGenre        | %genre%\n" +
+		"Year         | %year%\nTrack        | %track%/%track_total%\nDisc         | %disc%/%disc_total%\n" +
+		"Comment      | %comment%\nDuration     | %duration%\n" +
+		"Audio        | %bitrate% kbit/s, %sample_rate% Hz, %channels% channels"
+	DefaultListFormat   = "%marker%%index% [%filename%]"
+	DefaultStatusFormat = "%state%: %artist% – %title%\n[%elapsed%/%duration%] track %index%/%queue_size%, vol: %volume%"
 )
 
 var (
@@ -30,90 +45,185 @@ var (
 
 var HomeDir string
 
-type config struct {
-	VolLevel    float64 `toml:"volume_level"`
-	LogLevel    string  `toml:"log_level"`
-	SampleRate  int     `toml:"sample_rate"`
-	PlaylistDir string  `toml:"playlist_dir"`
+// Config holds the player settings stored in the config file.
+type Config struct {
+	Version int `toml:"config_version" json:"config_version"`
+
+	VolLevel    float64 `toml:"volume_level" json:"volume_level"`
+	LogLevel    string  `toml:"log_level" json:"log_level"`
+	SampleRate  int     `toml:"sample_rate" json:"sample_rate"`
+	PlaylistDir string  `toml:"playlist_dir" json:"playlist_dir"`
+	SocketPath  string  `toml:"socket_path" json:"socket_path"`
+
+	NowPlayingFile     string `toml:"now_playing_file" json:"now_playing_file"`
+	NowPlayingTemplate string `toml:"now_playing_template" json:"now_playing_template"`
+	NowPlayingJSON     string `toml:"now_playing_json" json:"now_playing_json"`
+
+	InfoFormat   string `toml:"info_format" json:"info_format"`
+	ListFormat   string `toml:"list_format" json:"list_format"`
+	StatusFormat string `toml:"status_format" json:"status_format"`
+
+	// LibraryWatch makes the player update the music library when files change.
+	LibraryWatch bool `toml:"library_watch" json:"library_watch"`
+
+	// SmartPlaylists maps the names of smart playlists to their queries,
+	// e.g. jazz = 'genre = "jazz" AND year < 1970 ORDER BY random LIMIT 50'.
+	SmartPlaylists map[string]string `toml:"smart_playlists" json:"smart_playlists,omitempty"`
 }
 
-// Load reads the TOML configuration file from the specified path.
-// If no path is provided, it loads from the default location in the user's home directory.
-// Returns a config pointer and any error encountered.
-func Load(argPath ...string) (*config, error) {
-	homeDir, err := env.GetHomeDir()
+// Path returns the default location of the config file: the path set in the
+// SCYTHIX_CONFIG environment variable, or conf.toml in the user's config directory.
+func Path() (string, error) {
+	if p := os.Getenv(PathEnv); p != "" {
+		return p, nil
+	}
+
+	dir, err := env.ConfigDir()
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	return path.Join(dir, confFileName), nil
+}
+
+// defaultPlaylistDir returns the playlists directory in the data directory of
+// the player, falling back to the legacy directory if it can't be determined.
+func defaultPlaylistDir() string {
+	dir, err := env.DataDir()
+	if err != nil {
+		return legacyPlaylistDir
+	}
+
+	return path.Join(dir, "playlists")
+}
+
+// Default returns the config with default settings.
+func Default() *Config {
+	return &Config{
+		Version:     CurrentVersion,
+		VolLevel:    defaultVolLevel,
+		SampleRate:  defaultSampleRate,
+		LogLevel:    defaultLogLevel,
+		PlaylistDir: defaultPlaylistDir(),
+
+		NowPlayingTemplate: DefaultNowPlayingTemplate,
+
+		InfoFormat:   DefaultInfoFormat,
+		ListFormat:   DefaultListFormat,
+		StatusFormat: DefaultStatusFormat,
 	}
+}
 
+// Load reads the TOML configuration file from the specified path.
+// If no path is provided, it loads from the default location returned by Path.
+// A file written by an older version of the player is migrated to the current layout
+// in memory, use Migrate to update the file itself.
+// Returns a config pointer and any error encountered.
+func Load(argPath ...string) (*Config, error) {
 	var confPath string
 	if len(argPath) == 0 {
-		confPath = path.Join(homeDir, defaultConfPath, confFileName)
+		p, err := Path()
+		if err != nil {
+			return nil, err
+		}
+		confPath = p
 	} else if len(argPath) == 1 {
 		confPath = argPath[0]
 	} else {
 		return nil, ErrTooManyArgs
 	}
 
-	cfg := &config{}
-
-	if _, err := toml.DecodeFile(confPath, cfg); err != nil {
+	cfg, _, err := decodeFile(confPath)
+	if err != nil {
 		return nil, err
 	}
 
 	return cfg, nil
 }
 
-// CreateDefault creates the default configuration directory and file
-// with predefined default settings. It returns the created config and any error.
-func CreateDefault() (*config, error) {
-	homeDir, err := env.GetHomeDir()
+// decodeFile reads the config file at the given path and migrates it to the current layout.
+// It returns the keys that were changed by the migrations along with the config.
+func decodeFile(confPath string) (*Config, []string, error) {
+	cfg, md, err := decodeFileMeta(confPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	confPath := path.Join(homeDir, defaultConfPath)
-	err = os.MkdirAll(confPath, 0755)
+	changed, err := migrate(cfg, md)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	conf := config{
-		VolLevel:    defaultVolLevel,
-		SampleRate:  defaultSampleRate,
-		LogLevel:    defaultLogLevel,
-		PlaylistDir: defaultPlaylistDir,
+	return cfg, changed, nil
+}
+
+// decodeFileMeta reads the config file at the given path without migrating it.
+// Keys missing from the file keep their default values.
+func decodeFileMeta(confPath string) (*Config, toml.MetaData, error) {
+	// The version is reset, since files without it predate versioning.
+	cfg := Default()
+	cfg.Version = 0
+
+	md, err := toml.DecodeFile(confPath, cfg)
+	if err != nil {
+		return nil, md, err
 	}
 
-	f, err := os.Create(path.Join(confPath, confFileName))
+	return cfg, md, nil
+}
+
+// CreateDefault creates the configuration directory and file with predefined
+// default settings at the specified path, or at the default location returned
+// by Path if no path is provided. It returns the created config and any error.
+func CreateDefault(argPath ...string) (*Config, error) {
+	var confPath string
+	if len(argPath) == 0 {
+		p, err := Path()
+		if err != nil {
+			return nil, err
+		}
+		confPath = p
+	} else if len(argPath) == 1 {
+		confPath = argPath[0]
+	} else {
+		return nil, ErrTooManyArgs
+	}
+
+	err := os.MkdirAll(path.Dir(confPath), 0755)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	encoder := toml.NewEncoder(f)
-	encoder.Encode(conf)
+	conf := Default()
+	if err := encodeFile(confPath, conf); err != nil {
+		return nil, err
+	}
 
-	return &conf, nil
+	return conf, nil
 }
 
 // Write saves the provided configuration struct to the default config file path.
 // Returns any error encountered during the write process.
-func Write(cfg *config) error {
-	homeDir, err := env.GetHomeDir()
+func Write(cfg *Config) error {
+	confPath, err := Path()
 	if err != nil {
 		return err
 	}
 
-	confPath := path.Join(homeDir, defaultConfPath, confFileName)
-	f, err := os.Create(confPath)
-	if err != nil {
+	return encodeFile(confPath, cfg)
+}
+
+// encodeFile replaces the config file at the given path with the encoded config.
+func encodeFile(path string, cfg *Config) error {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
 		return err
 	}
-	defer f.Close()
 
-	encoder := toml.NewEncoder(f)
-	encoder.Encode(cfg)
+	return writeFile(path, buf.Bytes())
+}
 
-	return nil
+// writeFile replaces the config file at the given path with the data.
+func writeFile(path string, data []byte) error {
+	return env.WriteFileAtomic(path, data, 0644)
 }
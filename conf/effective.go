@@ -0,0 +1,110 @@
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// envPrefix is the prefix of the environment variables overriding config keys.
+const envPrefix = "SCYTHIX_"
+
+// Override is a config value set on the command line.
+type Override struct {
+	Key   string
+	Value string
+	// Flag is the name of the command line flag the value comes from.
+	Flag string
+}
+
+// Sources maps the config keys to descriptions of where their values come from,
+// e.g. "default", "file /home/user/.config/scythix/conf.toml",
+// "env SCYTHIX_VOLUME_LEVEL" or "flag -socket".
+type Sources map[string]string
+
+// EnvName returns the name of the environment variable overriding the config key,
+// e.g. SCYTHIX_VOLUME_LEVEL for volume_level.
+func EnvName(key string) string {
+	return envPrefix + strings.ToUpper(key)
+}
+
+// LoadEffective builds the configuration the player runs with by merging, in the
+// order of increasing precedence, the default settings, the config file at the
+// given path (if it exists), the SCYTHIX_* environment variables and the command
+// line overrides. It also returns where each value comes from.
+func LoadEffective(path string, overrides []Override) (*Config, Sources, error) {
+	cfg := Default()
+	sources := Sources{}
+	for _, key := range Keys() {
+		sources[key] = "default"
+	}
+
+	fileCfg, md, err := decodeFileMeta(path)
+	switch {
+	case err == nil:
+		if _, err := migrate(fileCfg, md); err != nil {
+			return nil, nil, err
+		}
+		cfg = fileCfg
+		for _, key := range Keys() {
+			if md.IsDefined(key) {
+				sources[key] = "file " + path
+			}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, nil, err
+	}
+
+	for _, key := range Keys() {
+		value, ok := os.LookupEnv(EnvName(key))
+		if !ok || key == versionKey {
+			continue
+		}
+		if err := cfg.Set(key, value); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", EnvName(key), err)
+		}
+		sources[key] = "env " + EnvName(key)
+	}
+
+	for _, o := range overrides {
+		if err := cfg.Set(o.Key, o.Value); err != nil {
+			return nil, nil, fmt.Errorf("-%s: %w", o.Flag, err)
+		}
+		sources[o.Key] = "flag -" + o.Flag
+	}
+
+	return cfg, sources, nil
+}
+
+// Format returns the config in the TOML format, annotated with the sources of the values.
+func (c *Config) Format(sources Sources) (string, error) {
+	var sb strings.Builder
+	for _, key := range Keys() {
+		line, err := c.encodeKey(key)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%-40s # %s\n", line, sources[key])
+	}
+
+	return sb.String(), nil
+}
+
+// encodeKey returns the TOML line assigning the value of the config key.
+func (c *Config) encodeKey(key string) (string, error) {
+	field, err := c.field(key)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(map[string]any{key: field.Interface()}); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,170 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+var (
+	ErrUnknownKey  = fmt.Errorf("unknown config key")
+	ErrReadOnlyKey = fmt.Errorf("config key can't be set")
+)
+
+// Keys returns the names of the config keys in the order they are declared.
+// Tables, such as smart_playlists, are not keys, since they hold several values.
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() == reflect.Map {
+			continue
+		}
+		if key := t.Field(i).Tag.Get("toml"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Get returns the value of the config key formatted as a string.
+func (c *Config) Get(key string) (string, error) {
+	field, err := c.field(key)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprint(field.Interface()), nil
+}
+
+// Set parses the value according to the type of the config key and assigns it.
+// The config_version key is maintained by the migrations and can't be set.
+func (c *Config) Set(key, value string) error {
+	if key == versionKey {
+		return fmt.Errorf("%w: %s is updated when the file is migrated", ErrReadOnlyKey, key)
+	}
+	field, err := c.field(key)
+	if err != nil {
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected an integer: %w", key, err)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: expected a number: %w", key, err)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: expected true or false: %w", key, err)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("%s: unsupported value type %v", key, field.Kind())
+	}
+
+	return nil
+}
+
+// field returns the struct field of the config holding the key.
+func (c *Config) field(key string) (reflect.Value, error) {
+	rv := reflect.ValueOf(c).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if rv.Type().Field(i).Tag.Get("toml") == key && rv.Field(i).Kind() != reflect.Map {
+			return rv.Field(i), nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("%w: %q", ErrUnknownKey, key)
+}
+
+// UpdateFile sets the key to the value it has in the config in the file at the
+// given path. The rest of the file, including the keys changed by the user after
+// it was loaded, is kept intact. The key is added if the file doesn't have it.
+func UpdateFile(path string, cfg *Config, key string) error {
+	line, err := cfg.encodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Only top level keys are managed, so the search stops at the first table.
+	// Lines inside multi-line strings and arrays are skipped, which is the case
+	// if the document before them is incomplete.
+	content := string(b)
+	top := content
+	for _, loc := range tableHeaderRe.FindAllStringIndex(content, -1) {
+		if complete(content[:loc[0]]) {
+			top = content[:loc[0]]
+			break
+		}
+	}
+
+	newLine := line + "\n"
+	if start, end, ok := findKey(top, key); ok {
+		content = content[:start] + newLine + content[end:]
+	} else {
+		if top != "" && !strings.HasSuffix(top, "\n") {
+			newLine = "\n" + newLine
+		}
+		content = top + newLine + content[len(top):]
+	}
+
+	return writeFile(path, []byte(content))
+}
+
+// findKey returns the byte range of the lines holding the key and its value,
+// which may continue on the following lines, in the TOML document.
+func findKey(doc, key string) (start, end int, ok bool) {
+	keyRe := regexp.MustCompile(`(?m)^[ \t]*` + regexp.QuoteMeta(key) + `[ \t]*=`)
+	for _, loc := range keyRe.FindAllStringIndex(doc, -1) {
+		if !complete(doc[:loc[0]]) {
+			continue
+		}
+		for end = loc[1]; end < len(doc); {
+			if i := strings.IndexByte(doc[end:], '\n'); i >= 0 {
+				end += i + 1
+			} else {
+				end = len(doc)
+			}
+			if complete(doc[:end]) {
+				break
+			}
+		}
+
+		return loc[0], end, true
+	}
+
+	return 0, 0, false
+}
+
+// complete reports whether the text is a valid TOML document, so that it doesn't
+// end inside a multi-line string or array.
+func complete(doc string) bool {
+	var v map[string]any
+	_, err := toml.Decode(doc, &v)
+
+	return err == nil
+}
+
+var tableHeaderRe = regexp.MustCompile(`(?m)^[ \t]*\[`)
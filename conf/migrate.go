@@ -0,0 +1,161 @@
+package conf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"scythix/env"
+)
+
+// CurrentVersion is the version of the config file layout written by this
+// version of the player. Files without the config_version key have version 0.
+// Keys added to the config don't change the layout, since keys missing from a
+// file take their default values.
+const CurrentVersion = 3
+
+var ErrUnsupportedVersion = fmt.Errorf("unsupported config version")
+
+// migration upgrades a config from the previous version to the next one.
+// It returns the keys it has changed.
+type migration func(cfg *Config, md toml.MetaData) []string
+
+// migrations holds the migration from version i to version i+1 at index i.
+var migrations = []migration{
+	migrateToV1,
+	migrateToV2,
+	migrateToV3,
+}
+
+// migrateToV1 fills in the keys missing from files written before the config was
+// versioned. Such files were created with the keys known at that time only, and
+// keys deleted by the user were silently treated as zero values.
+func migrateToV1(cfg *Config, md toml.MetaData) []string {
+	keys := slices.DeleteFunc(Keys(), func(key string) bool { return key == versionKey })
+	return fillDefaults(cfg, md, keys)
+}
+
+// migrateToV2 converts the {key} placeholders of now_playing_template to the
+// %key% placeholders used by the format keys.
+func migrateToV2(cfg *Config, md toml.MetaData) []string {
+	if !md.IsDefined("now_playing_template") {
+		return nil
+	}
+	cfg.NowPlayingTemplate = convertBraces(cfg.NowPlayingTemplate)
+
+	return []string{"now_playing_template"}
+}
+
+// v1TemplateKeys are the placeholders now_playing_template supported up to version 1.
+var v1TemplateKeys = []string{
+	"filename", "title", "artist", "album", "album_artist", "composer", "genre", "comment",
+	"year", "track", "track_total", "disc", "disc_total", "duration", "bitrate", "channels",
+	"sample_rate",
+}
+
+// convertBraces converts a template with the {key} placeholders of version 1 to
+// the %key% placeholders of the format keys. Literal percent signs are doubled,
+// and braces not enclosing a known key are kept.
+func convertBraces(tmpl string) string {
+	tmpl = strings.ReplaceAll(tmpl, "%", "%%")
+
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		sb.WriteString(tmpl[:start])
+		if slices.Contains(v1TemplateKeys, tmpl[start+1:end]) {
+			sb.WriteString("%" + tmpl[start+1:end] + "%")
+		} else {
+			sb.WriteString(tmpl[start : end+1])
+		}
+		tmpl = tmpl[end+1:]
+	}
+	sb.WriteString(tmpl)
+
+	return sb.String()
+}
+
+// migrateToV3 moves the default playlist_dir from ~/Scythix to the data directory
+// of the player. A legacy directory which exists is kept, so that the playlists
+// saved there stay available.
+func migrateToV3(cfg *Config, md toml.MetaData) []string {
+	if cfg.PlaylistDir != legacyPlaylistDir {
+		return nil
+	}
+	if dir, err := env.ExpandPath(legacyPlaylistDir); err != nil || env.PathExists(dir) {
+		return nil
+	}
+	cfg.PlaylistDir = defaultPlaylistDir()
+
+	return []string{"playlist_dir"}
+}
+
+// fillDefaults sets the given keys missing from the file to their default values.
+// It returns the keys it has changed.
+func fillDefaults(cfg *Config, md toml.MetaData, keys []string) []string {
+	defaults := Default()
+	changed := []string{}
+	for _, key := range keys {
+		if md.IsDefined(key) {
+			continue
+		}
+		value, _ := defaults.field(key)
+		field, _ := cfg.field(key)
+		field.Set(value)
+		changed = append(changed, key)
+	}
+
+	return changed
+}
+
+// migrate upgrades the decoded config to the current version.
+// It returns the keys changed by the migrations, including config_version.
+func migrate(cfg *Config, md toml.MetaData) ([]string, error) {
+	if cfg.Version > CurrentVersion || cfg.Version < 0 {
+		return nil, fmt.Errorf("%w: %d (supported up to %d)", ErrUnsupportedVersion, cfg.Version, CurrentVersion)
+	}
+	if cfg.Version == CurrentVersion {
+		return nil, nil
+	}
+
+	changed := []string{}
+	for v := cfg.Version; v < CurrentVersion; v++ {
+		for _, key := range migrations[v](cfg, md) {
+			if !slices.Contains(changed, key) {
+				changed = append(changed, key)
+			}
+		}
+	}
+	cfg.Version = CurrentVersion
+
+	return append(changed, versionKey), nil
+}
+
+// Migrate upgrades the config file at the given path to the current layout.
+// Only the keys changed by the migrations are written, the rest of the file is
+// kept intact. It reports whether the file was changed.
+func Migrate(path string) (bool, error) {
+	cfg, changed, err := decodeFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	for _, key := range changed {
+		if err := UpdateFile(path, cfg, key); err != nil {
+			return false, err
+		}
+	}
+
+	return len(changed) > 0, nil
+}
@@ -0,0 +1,22 @@
+package conf
+
+import "testing"
+
+func TestConvertBraces(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{artist} – {title}", "%artist% – %title%"},
+		{"{title} 100%", "%title% 100%%"},
+		{"{nope} {album}", "{nope} %album%"},
+		{"{title", "{title"},
+		{"plain", "plain"},
+	}
+
+	for _, tt := range tests {
+		if got := convertBraces(tt.tmpl); got != tt.want {
+			t.Errorf("convertBraces(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package conf
+
+import (
+	"bytes"
+
+	"github.com/BurntSushi/toml"
+)
+
+// State holds the settings a named player instance remembers between runs.
+// The default instance keeps them in the config file instead.
+type State struct {
+	VolLevel float64 `toml:"volume_level"`
+}
+
+// LoadState reads the state file at the given path.
+func LoadState(path string) (*State, error) {
+	st := &State{}
+	if _, err := toml.DecodeFile(path, st); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// WriteState saves the state to the file at the given path.
+func WriteState(path string, st *State) error {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(st); err != nil {
+		return err
+	}
+
+	return writeFile(path, buf.Bytes())
+}
@@ -0,0 +1,114 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	versionKey = "config_version"
+
+	minVolLevel   = 0
+	maxVolLevel   = 24
+	minSampleRate = 8000
+	maxSampleRate = 192000
+)
+
+// LogLevels lists the values accepted by the log_level key.
+var LogLevels = []string{"debug", "info", "warn", "error", "fatal", "panic"}
+
+var ErrInvalidConfig = fmt.Errorf("invalid config")
+
+// Validate checks the config values. The returned error describes every problem found,
+// one per line. The formats and the queries of the smart playlists are checked by
+// the player, which knows their syntax.
+func (c *Config) Validate() error {
+	if errs := c.validate(); len(errs) > 0 {
+		return fmt.Errorf("%w:\n%w", ErrInvalidConfig, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// validate returns the problems found in the config values.
+func (c *Config) validate() []error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	if c.VolLevel < minVolLevel || c.VolLevel > maxVolLevel {
+		invalid("volume_level", "%g is out of range %d–%d", c.VolLevel, minVolLevel, maxVolLevel)
+	}
+	if !slices.Contains(LogLevels, c.LogLevel) {
+		invalid("log_level", "unknown level %q, expected one of %s", c.LogLevel, strings.Join(LogLevels, ", "))
+	}
+	if c.SampleRate < minSampleRate || c.SampleRate > maxSampleRate {
+		invalid("sample_rate", "%d Hz is out of range %d–%d", c.SampleRate, minSampleRate, maxSampleRate)
+	}
+	if c.PlaylistDir == "" {
+		invalid("playlist_dir", "must not be empty")
+	}
+	if c.SocketPath != "" && !filepath.IsAbs(c.SocketPath) {
+		invalid("socket_path", "%q must be an absolute path", c.SocketPath)
+	}
+	for _, key := range []string{"now_playing_file", "now_playing_json"} {
+		p, _ := c.Get(key)
+		if p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			invalid(key, "%q must be an absolute path", p)
+		} else if fi, err := os.Stat(filepath.Dir(p)); err != nil || !fi.IsDir() {
+			invalid(key, "directory %q does not exist", filepath.Dir(p))
+		}
+	}
+
+	return errs
+}
+
+// Check reads the config file at the given path and returns the problems found in it:
+// syntax errors, unknown keys, invalid values and an outdated layout.
+func Check(path string) ([]string, error) {
+	// Keys missing from the file keep their default values. The version is
+	// reset, since files without it predate versioning.
+	cfg := Default()
+	cfg.Version = 0
+	md, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return []string{parseErr.ErrorWithPosition()}, nil
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return []string{err.Error()}, nil
+	}
+
+	problems := []string{}
+	for _, key := range md.Undecoded() {
+		problems = append(problems, fmt.Sprintf("%s: unknown key", key))
+	}
+
+	version := cfg.Version
+	if _, err := migrate(cfg, md); err != nil {
+		return append(problems, err.Error()), nil
+	}
+	if version < CurrentVersion {
+		problems = append(problems, fmt.Sprintf("%s: layout version %d is outdated, "+
+			"it will be migrated to version %d when the player starts", versionKey, version, CurrentVersion))
+	}
+
+	for _, err := range cfg.validate() {
+		problems = append(problems, err.Error())
+	}
+
+	return problems, nil
+}
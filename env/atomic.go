@@ -0,0 +1,74 @@
+package env
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// WriteFileAtomic writes data to the named file so that readers never observe
+// a partially written file. See ReplaceFile.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return ReplaceFile(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// ReplaceFile writes the contents produced by write to a temporary file in the
+// same directory as the named file, which is then renamed over it, so that an
+// interrupted write leaves the file intact. If the path is a symbolic link, the
+// file it points to is replaced. The owner and group of an existing file are kept.
+func ReplaceFile(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, "."+name+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	if err := writeTemp(f, path, perm, write); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// writeTemp writes the contents of the temporary file replacing the file at path
+// and gives it the permissions, as well as the owner and group of the file.
+func writeTemp(f *os.File, path string, perm os.FileMode, write func(w io.Writer) error) error {
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			if err := f.Chown(int(st.Uid), int(st.Gid)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return f.Sync()
+}
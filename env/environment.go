@@ -6,6 +6,9 @@ package env
 import (
 	"fmt"
 	"os"
+	"path"
+	"strings"
+	"syscall"
 )
 
 var ErrInvalidPath = fmt.Errorf("invalid path specified")
@@ -27,3 +30,117 @@ func PathExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+// RuntimeDir returns the directory for the runtime files of the player, such as
+// the control socket and the lock file. It is $XDG_RUNTIME_DIR/scythix, or a
+// per-user directory in /tmp if XDG_RUNTIME_DIR is not set.
+// The directory is created if needed and must be private to the current user.
+func RuntimeDir() (string, error) {
+	dir := path.Join(os.TempDir(), fmt.Sprintf("scythix-%d", os.Getuid()))
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" && path.IsAbs(runtimeDir) {
+		dir = path.Join(runtimeDir, "scythix")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	// The fallback directory lives in a world-writable location,
+	// so make sure it has not been created by someone else.
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || !ok || int(st.Uid) != os.Getuid() {
+		return "", fmt.Errorf("%w: %s is not a directory owned by the current user", ErrInvalidPath, dir)
+	}
+	if fi.Mode().Perm() != 0700 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// xdgDir returns the base directory named by the XDG environment variable.
+// If the variable is unset or holds a relative path, which the XDG Base Directory
+// specification requires to ignore, the default path relative to the home
+// directory is returned.
+func xdgDir(envVar, defaultRel string) (string, error) {
+	if dir := os.Getenv(envVar); dir != "" && path.IsAbs(dir) {
+		return dir, nil
+	}
+
+	homeDir, err := GetHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(homeDir, defaultRel), nil
+}
+
+// ConfigDir returns the directory for the config files of the player.
+// It is $XDG_CONFIG_HOME/scythix, defaulting to ~/.config/scythix.
+func ConfigDir() (string, error) {
+	dir, err := xdgDir("XDG_CONFIG_HOME", ".config")
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, "scythix"), nil
+}
+
+// DataDir returns the directory for the data files of the player.
+// It is $XDG_DATA_HOME/scythix, defaulting to ~/.local/share/scythix.
+func DataDir() (string, error) {
+	dir, err := xdgDir("XDG_DATA_HOME", ".local/share")
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, "scythix"), nil
+}
+
+// CacheHome returns the base directory for the cache files of the user.
+// It is $XDG_CACHE_HOME, defaulting to ~/.cache.
+func CacheHome() (string, error) {
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+// StateDir returns the directory for the state files of the player, which
+// persist between runs. It is $XDG_STATE_HOME/scythix, defaulting to
+// ~/.local/state/scythix. The directory is created if needed.
+func StateDir() (string, error) {
+	stateHome, err := xdgDir("XDG_STATE_HOME", ".local/state")
+	if err != nil {
+		return "", err
+	}
+
+	dir := path.Join(stateHome, "scythix")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// ExpandPath expands environment variables and a leading "~/" in the path.
+// A relative path is resolved against the user's home directory.
+func ExpandPath(p string) (string, error) {
+	p = os.ExpandEnv(p)
+	if path.IsAbs(p) {
+		return p, nil
+	}
+
+	homeDir, err := GetHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if p == "~" {
+		return homeDir, nil
+	}
+
+	return path.Join(homeDir, strings.TrimPrefix(p, "~/")), nil
+}
@@ -0,0 +1,186 @@
+// Package fswatch reports changes of files in watched directories using
+// the Linux inotify API.
+package fswatch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Op describes the kind of a file system change.
+type Op uint32
+
+const (
+	// Create is reported when a file is created in a watched directory.
+	Create Op = syscall.IN_CREATE
+	// Write is reported when a file opened for writing is closed.
+	Write Op = syscall.IN_CLOSE_WRITE
+	// Remove is reported when a file is deleted from a watched directory.
+	Remove Op = syscall.IN_DELETE
+	// MovedFrom is reported when a file is moved out of a watched directory.
+	MovedFrom Op = syscall.IN_MOVED_FROM
+	// MovedTo is reported when a file is moved into a watched directory.
+	MovedTo Op = syscall.IN_MOVED_TO
+	// RemoveSelf is reported when a watched directory itself is deleted.
+	RemoveSelf Op = syscall.IN_DELETE_SELF
+	// Overflow is reported without a path when the kernel dropped events because
+	// they were not read fast enough. The watched directories have to be read again.
+	Overflow Op = syscall.IN_Q_OVERFLOW
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// Event describes a change of a file in a watched directory.
+type Event struct {
+	// Path is the path of the changed file.
+	Path string
+	Op   Op
+	// IsDir reports whether the changed file is a directory.
+	IsDir bool
+	// Cookie relates the MovedFrom and MovedTo events of a single rename.
+	Cookie uint32
+}
+
+// Has reports whether the event includes the given operation.
+func (e Event) Has(op Op) bool {
+	return e.Op&op != 0
+}
+
+// Watcher watches directories for changes of the files in them.
+// Subdirectories are not watched unless added explicitly.
+type Watcher struct {
+	// Events delivers the changes in the watched directories.
+	Events chan Event
+	// Errors delivers the errors that occurred while reading events.
+	Errors chan error
+
+	fd      int
+	f       *os.File
+	mu      sync.Mutex
+	watches map[int32]string
+	paths   map[string]int32
+}
+
+// New creates a watcher and starts reading events. Close must be called
+// to release its resources.
+func New() (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+
+	w := &Watcher{
+		Events:  make(chan Event, 64),
+		Errors:  make(chan error, 1),
+		fd:      fd,
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		watches: map[int32]string{},
+		paths:   map[string]int32{},
+	}
+	go w.readEvents()
+
+	return w, nil
+}
+
+// Add starts watching the directory.
+func (w *Watcher) Add(dir string) error {
+	dir = filepath.Clean(dir)
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		return fmt.Errorf("watch %s: %w", dir, err)
+	}
+
+	w.mu.Lock()
+	w.watches[int32(wd)] = dir
+	w.paths[dir] = int32(wd)
+	w.mu.Unlock()
+
+	return nil
+}
+
+// Remove stops watching the directory and its subdirectories added to the watcher.
+func (w *Watcher) Remove(dir string) {
+	dir = filepath.Clean(dir)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for path, wd := range w.paths {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.paths, path)
+			delete(w.watches, wd)
+		}
+	}
+}
+
+// Close stops watching all directories. The Events channel is closed afterwards.
+// The file descriptor is only accessed through the os.File, which keeps it in
+// non-blocking mode so that Close interrupts a pending read.
+func (w *Watcher) Close() error {
+	return w.f.Close()
+}
+
+// readEvents decodes the events read from the inotify file until it is closed.
+func (w *Watcher) readEvents() {
+	defer close(w.Events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				select {
+				case w.Errors <- err:
+				default:
+				}
+			}
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			cookie := binary.NativeEndian.Uint32(buf[off+8:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			name := strings.TrimRight(string(buf[off+syscall.SizeofInotifyEvent:off+syscall.SizeofInotifyEvent+nameLen]), "\x00")
+			off += syscall.SizeofInotifyEvent + nameLen
+
+			if mask&syscall.IN_Q_OVERFLOW != 0 {
+				w.Events <- Event{Op: Overflow}
+				continue
+			}
+
+			if mask&syscall.IN_IGNORED != 0 {
+				w.mu.Lock()
+				if dir, ok := w.watches[wd]; ok {
+					delete(w.paths, dir)
+					delete(w.watches, wd)
+				}
+				w.mu.Unlock()
+				continue
+			}
+
+			w.mu.Lock()
+			dir, ok := w.watches[wd]
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			w.Events <- Event{
+				Path:   filepath.Join(dir, name),
+				Op:     Op(mask & watchMask),
+				IsDir:  mask&syscall.IN_ISDIR != 0,
+				Cookie: cookie,
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Package id3 holds the definitions of the ID3v2 tag format shared by the
+// packages reading and writing tags.
+package id3
+
+// Text encodings of ID3v2 frames, given by the first byte of their body.
+const (
+	EncodingISO88591 = 0
+	EncodingUTF16    = 1 // with byte order mark
+	EncodingUTF16BE  = 2
+	EncodingUTF8     = 3
+)
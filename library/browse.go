@@ -0,0 +1,172 @@
+package library
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrAmbiguousAlbum = fmt.Errorf("albums of several artists have the title, choose one with -artist")
+
+// Group is a tag value shared by tracks of the library, e.g. an artist or a genre.
+type Group struct {
+	Name     string
+	Tracks   int
+	Duration time.Duration
+}
+
+// Album is an album of the library. Its artist is the album artist of its tracks,
+// or the artist of the first track if they have none.
+type Album struct {
+	Title    string
+	Artist   string
+	Year     int
+	Tracks   int
+	Duration time.Duration
+}
+
+// Artists returns the artists of the tracks ordered by name. Tracks without
+// artist are left out.
+func (l *Library) Artists() []Group {
+	return l.groups(func(t *Track) string { return t.Prop.Artist })
+}
+
+// Genres returns the genres of the tracks ordered by name. Tracks without
+// genre are left out.
+func (l *Library) Genres() []Group {
+	return l.groups(func(t *Track) string { return t.Prop.Genre })
+}
+
+// groups groups the tracks by the value of a tag, ignoring case. The group is
+// named after the first spelling found in the order of Sorted.
+func (l *Library) groups(value func(*Track) string) []Group {
+	byKey := map[string]*Group{}
+	var groups []*Group
+	for _, t := range l.Sorted() {
+		name := strings.TrimSpace(value(t))
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		g, ok := byKey[key]
+		if !ok {
+			g = &Group{Name: name}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.Tracks++
+		g.Duration += t.Prop.Duration
+	}
+
+	result := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	slices.SortFunc(result, func(a, b Group) int {
+		return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	return result
+}
+
+// albumArtist returns the artist an album of the track is listed under.
+func albumArtist(t *Track) string {
+	if t.Prop.AlbumArtist != "" {
+		return t.Prop.AlbumArtist
+	}
+	return t.Prop.Artist
+}
+
+// albumKey identifies the album of the track, ignoring case. The tracks of a
+// compilation share the album artist, so they form a single album.
+func albumKey(t *Track) string {
+	return strings.ToLower(t.Prop.Album) + "\x00" + strings.ToLower(albumArtist(t))
+}
+
+// Albums returns the albums ordered by artist and title. If artist is not empty,
+// only the albums of the artist are returned, see Album. Tracks without album
+// are left out.
+func (l *Library) Albums(artist string) []Album {
+	var albums []Album
+	for _, tracks := range l.albums(artist) {
+		a := Album{Title: tracks[0].Prop.Album, Artist: albumArtist(tracks[0])}
+		for _, t := range tracks {
+			a.Tracks++
+			a.Duration += t.Prop.Duration
+			if a.Year == 0 {
+				a.Year = t.Prop.Year
+			}
+		}
+		albums = append(albums, a)
+	}
+
+	return albums
+}
+
+// Album returns the tracks of the album with the given title, ignoring case, in
+// release order: by disc and track number. If albums of several artists have the
+// title, ErrAmbiguousAlbum is returned unless artist selects one of them: the album
+// artist, or the artist of one of the tracks, must contain it, ignoring case.
+// No tracks are returned if there is no such album.
+func (l *Library) Album(title, artist string) ([]*Track, error) {
+	var found [][]*Track
+	for _, album := range l.albums(artist) {
+		if strings.EqualFold(album[0].Prop.Album, title) {
+			found = append(found, album)
+		}
+	}
+	if len(found) > 1 {
+		artists := make([]string, 0, len(found))
+		for _, album := range found {
+			artists = append(artists, albumArtist(album[0]))
+		}
+		return nil, fmt.Errorf("%w: %q by %s", ErrAmbiguousAlbum, found[0][0].Prop.Album, strings.Join(artists, ", "))
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	return found[0], nil
+}
+
+// albums returns the tracks of every album of the artist, or of every album if
+// artist is empty, ordered by artist and title, and by disc and track number
+// within an album.
+func (l *Library) albums(artist string) [][]*Track {
+	var tracks []*Track
+	for _, t := range l.Tracks {
+		if strings.TrimSpace(t.Prop.Album) != "" {
+			tracks = append(tracks, t)
+		}
+	}
+	slices.SortFunc(tracks, func(a, b *Track) int {
+		return cmp.Or(
+			cmp.Compare(strings.ToLower(albumArtist(a)), strings.ToLower(albumArtist(b))),
+			cmp.Compare(strings.ToLower(a.Prop.Album), strings.ToLower(b.Prop.Album)),
+			cmp.Compare(a.Prop.Disc, b.Prop.Disc),
+			cmp.Compare(a.Prop.Track, b.Prop.Track),
+			cmp.Compare(a.Path, b.Path),
+		)
+	})
+
+	var albums [][]*Track
+	for i, t := range tracks {
+		if i == 0 || albumKey(t) != albumKey(tracks[i-1]) {
+			albums = append(albums, nil)
+		}
+		albums[len(albums)-1] = append(albums[len(albums)-1], t)
+	}
+	if artist == "" {
+		return albums
+	}
+
+	artist = strings.ToLower(artist)
+	return slices.DeleteFunc(albums, func(album []*Track) bool {
+		return !slices.ContainsFunc(album, func(t *Track) bool {
+			return strings.Contains(strings.ToLower(albumArtist(t)), artist) ||
+				strings.Contains(strings.ToLower(t.Prop.Artist), artist)
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package library
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestAlbum(t *testing.T) {
+	tests := []struct {
+		title, artist string
+		want          []string
+		wantErr       error
+	}{
+		{title: "kind of blue", want: []string{"So What", "Freddie Freeloader"}},
+		{title: "Low", artist: "bowie", want: []string{"Speed of Life", "Breaking Glass"}},
+		{title: "Low", artist: "Other", want: []string{"Low"}},
+		{title: "Low", wantErr: ErrAmbiguousAlbum},
+		{title: "Missing"},
+	}
+
+	l := testLibrary()
+	for _, tt := range tests {
+		tracks, err := l.Album(tt.title, tt.artist)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Album(%q, %q) error = %v, want %v", tt.title, tt.artist, err, tt.wantErr)
+			continue
+		}
+		if got := titles(tracks); !slices.Equal(got, tt.want) {
+			t.Errorf("Album(%q, %q) = %q, want %q", tt.title, tt.artist, got, tt.want)
+		}
+	}
+}
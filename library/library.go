@@ -0,0 +1,361 @@
+// Package library maintains a database of the audio files found in the music
+// directories of the user, with their tags and stream properties, so that
+// tracks can be searched for and queued without walking the directories again.
+package library
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"scythix/env"
+	"scythix/playlist"
+)
+
+var (
+	ErrNoLibrary          = fmt.Errorf("the library is empty, run 'scythix library scan DIR' first")
+	ErrUnsupportedVersion = fmt.Errorf("unsupported library database version")
+	ErrLocked             = fmt.Errorf("the library is being updated by another process")
+)
+
+// fileName is the name of the database file in the data directory.
+const fileName = "library.gob"
+
+// Version is the layout of the database written by this version of the player.
+// A database of another version has to be scanned again.
+const Version = 1
+
+// Track is an audio file of the library.
+type Track struct {
+	Path    string
+	Prop    playlist.AudioProperties
+	ModTime time.Time
+	Size    int64
+}
+
+// Library is the database of the audio files found in the scanned directories.
+type Library struct {
+	Version int
+	// Roots are the scanned directories.
+	Roots   []string
+	Tracks  map[string]*Track
+	Updated time.Time
+
+	path string
+	// lock is the lock file held while the library is opened for update.
+	lock *os.File
+}
+
+// ScanStats counts the changes made to the library by a scan.
+type ScanStats struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int
+	Failed    int
+}
+
+// Path returns the default location of the database: library.gob in the data directory.
+func Path() (string, error) {
+	dir, err := env.DataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, fileName), nil
+}
+
+// Open reads the database at the given path. A missing file yields an empty library,
+// which is created by Save.
+func Open(dbPath string) (*Library, error) {
+	l := &Library{Version: Version, Tracks: map[string]*Track{}, path: dbPath}
+
+	b, err := os.ReadFile(dbPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(l); err != nil {
+		return nil, fmt.Errorf("unable to read library %s: %w", dbPath, err)
+	}
+	if l.Version != Version {
+		return nil, fmt.Errorf("%w: %d, run 'scythix library scan' again", ErrUnsupportedVersion, l.Version)
+	}
+	if l.Tracks == nil {
+		l.Tracks = map[string]*Track{}
+	}
+
+	return l, nil
+}
+
+// OpenForUpdate reads the database at the given path like Open and locks it, so
+// that changes saved by other processes in the meantime are not overwritten.
+// Unless wait is set, ErrLocked is returned if another process holds the lock.
+// The lock is held until Close is called.
+func OpenForUpdate(dbPath string, wait bool) (*Library, error) {
+	// The database is replaced when it is saved, so a separate file is locked.
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(dbPath+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+
+	l, err := Open(dbPath)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.lock = f
+
+	return l, nil
+}
+
+// Close releases the lock taken by OpenForUpdate. It does nothing for a library
+// returned by Open.
+func (l *Library) Close() error {
+	if l.lock == nil {
+		return nil
+	}
+	err := l.lock.Close()
+	l.lock = nil
+
+	return err
+}
+
+// Save writes the database to the file it was opened from.
+func (l *Library) Save() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(l); err != nil {
+		return err
+	}
+
+	return env.WriteFileAtomic(l.path, buf.Bytes(), 0644)
+}
+
+// Scan indexes the supported audio files found in the directory recursively.
+// Files of the directory that no longer exist are removed from the library.
+// Unless full is set, files whose modification time and size are unchanged
+// since they were indexed are not read again.
+// The report function, if not nil, is called for every file that can't be indexed.
+func (l *Library) Scan(root string, full bool, report func(path string, err error)) (ScanStats, error) {
+	var stats ScanStats
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return stats, err
+	}
+
+	seen := map[string]bool{}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			// Unreadable subdirectories are skipped.
+			if report != nil {
+				report(p, err)
+			}
+			return nil
+		}
+		if d.IsDir() || !playlist.IsAudioFile(p) {
+			return nil
+		}
+
+		if t, ok := l.Tracks[p]; ok && !full {
+			if fi, err := d.Info(); err == nil && t.ModTime.Equal(fi.ModTime()) && t.Size == fi.Size() {
+				seen[p] = true
+				stats.Unchanged++
+				return nil
+			}
+		}
+
+		track, err := readTrack(p)
+		if err != nil {
+			stats.Failed++
+			if report != nil {
+				report(p, err)
+			}
+			return nil
+		}
+		seen[p] = true
+		if _, ok := l.Tracks[p]; ok {
+			stats.Updated++
+		} else {
+			stats.Added++
+		}
+		l.Tracks[p] = track
+
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	for p := range l.Tracks {
+		if InDir(p, root) && !seen[p] {
+			delete(l.Tracks, p)
+			stats.Removed++
+		}
+	}
+	// Only the topmost scanned directories are kept, the others are rescanned with them.
+	if !slices.ContainsFunc(l.Roots, func(r string) bool { return r == root || InDir(root, r) }) {
+		l.Roots = slices.DeleteFunc(l.Roots, func(r string) bool { return InDir(r, root) })
+		l.Roots = append(l.Roots, root)
+		slices.Sort(l.Roots)
+	}
+	l.Updated = time.Now()
+
+	return stats, nil
+}
+
+// Update reads the audio file again and adds or replaces its track.
+func (l *Library) Update(p string) error {
+	track, err := readTrack(p)
+	if err != nil {
+		return err
+	}
+	l.Tracks[p] = track
+	l.Updated = time.Now()
+
+	return nil
+}
+
+// Remove removes the track of the file, or the tracks of the files in the
+// directory, and returns the number of removed tracks.
+func (l *Library) Remove(p string) int {
+	n := 0
+	for tp := range l.Tracks {
+		if tp == p || InDir(tp, p) {
+			delete(l.Tracks, tp)
+			n++
+		}
+	}
+	if n > 0 {
+		l.Updated = time.Now()
+	}
+
+	return n
+}
+
+// Rename moves the track of the file, or the tracks of the files in the directory,
+// to the new path, and returns the number of moved tracks.
+func (l *Library) Rename(from, to string) int {
+	var moved []*Track
+	for tp, t := range l.Tracks {
+		if tp == from || InDir(tp, from) {
+			moved = append(moved, t)
+			delete(l.Tracks, tp)
+		}
+	}
+	for _, t := range moved {
+		t.Path = to + strings.TrimPrefix(t.Path, from)
+		t.Prop.FileName = filepath.Base(t.Path)
+		l.Tracks[t.Path] = t
+	}
+	if len(moved) > 0 {
+		l.Updated = time.Now()
+	}
+
+	return len(moved)
+}
+
+// readTrack reads the tags and the stream properties of the audio file.
+func readTrack(p string) (*Track, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	song, err := playlist.NewSong(p)
+	if err != nil {
+		return nil, err
+	}
+	song.Streamer.Close()
+
+	track := &Track{Path: p, Prop: *song.Prop, ModTime: fi.ModTime(), Size: fi.Size()}
+	clearPlaceholders(&track.Prop)
+
+	return track, nil
+}
+
+// noTag is the value playlist.NewAudioProperties gives the title, artist, album
+// and genre of a file whose tags can't be read.
+const noTag = "-"
+
+// clearPlaceholders empties the tags set to noTag, so that untagged tracks are
+// not taken for tracks tagged with it.
+func clearPlaceholders(prop *playlist.AudioProperties) {
+	for _, value := range []*string{&prop.Title, &prop.Artist, &prop.Album, &prop.Genre} {
+		if *value == noTag {
+			*value = ""
+		}
+	}
+}
+
+// InDir reports whether the path is inside the directory.
+func InDir(p, dir string) bool {
+	return strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// Sorted returns the tracks ordered by artist, album, disc and track number, and path.
+func (l *Library) Sorted() []*Track {
+	tracks := make([]*Track, 0, len(l.Tracks))
+	for _, t := range l.Tracks {
+		tracks = append(tracks, t)
+	}
+	slices.SortFunc(tracks, compareTracks)
+
+	return tracks
+}
+
+// compareTracks orders tracks by artist, album, disc and track number, and path.
+func compareTracks(a, b *Track) int {
+	return cmp.Or(
+		cmp.Compare(strings.ToLower(a.Prop.Artist), strings.ToLower(b.Prop.Artist)),
+		cmp.Compare(strings.ToLower(a.Prop.Album), strings.ToLower(b.Prop.Album)),
+		cmp.Compare(a.Prop.Disc, b.Prop.Disc),
+		cmp.Compare(a.Prop.Track, b.Prop.Track),
+		cmp.Compare(a.Path, b.Path),
+	)
+}
+
+// Search returns the tracks matching the query, in the order of Sorted.
+func (l *Library) Search(q *Query) []*Track {
+	var found []*Track
+	for _, t := range l.Sorted() {
+		if q.Match(t) {
+			found = append(found, t)
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,223 @@
+package library
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidQuery = fmt.Errorf("invalid query")
+
+// Names of the fields a query can match.
+const (
+	FieldTitle       = "title"
+	FieldArtist      = "artist"
+	FieldAlbum       = "album"
+	FieldAlbumArtist = "album-artist"
+	FieldComposer    = "composer"
+	FieldGenre       = "genre"
+	FieldYear        = "year"
+	FieldTrack       = "track"
+	FieldDisc        = "disc"
+	FieldDuration    = "duration"
+	FieldComment     = "comment"
+	FieldPath        = "path"
+	FieldFileName    = "filename"
+)
+
+// QueryFields returns the names of the fields a query can match.
+func QueryFields() []string {
+	return []string{
+		FieldTitle, FieldArtist, FieldAlbum, FieldAlbumArtist, FieldComposer, FieldGenre,
+		FieldYear, FieldTrack, FieldDisc, FieldDuration, FieldComment, FieldPath, FieldFileName,
+	}
+}
+
+// textFields are the fields matched by a word given without a field name.
+var textFields = []string{FieldTitle, FieldArtist, FieldAlbum, FieldAlbumArtist, FieldComposer, FieldFileName}
+
+// term is a condition of a query. Text fields match if they contain the value,
+// ignoring case, numeric fields match if they are in the range min–max.
+type term struct {
+	field    string // empty for a word matching any of the text fields
+	value    string
+	min, max int
+}
+
+// Query selects tracks of the library. A track matches if it meets every term.
+type Query struct {
+	terms []term
+}
+
+// ParseQuery parses a query made of words separated by spaces. A word of the
+// form field:value matches the field, any other word matches one of the title,
+// artist, album, album artist, composer and file name. Values containing spaces
+// are quoted, e.g. artist:"david bowie". The year, track and disc take a number
+// or a range, e.g. year:1970-1979, and so does the duration in seconds.
+func ParseQuery(s string) (*Query, error) {
+	words, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%w: empty query", ErrInvalidQuery)
+	}
+
+	q := &Query{}
+	for _, word := range words {
+		field, value, found := strings.Cut(word, ":")
+		if !found {
+			q.terms = append(q.terms, term{value: strings.ToLower(word)})
+			continue
+		}
+
+		field = strings.ToLower(field)
+		if !slices.Contains(QueryFields(), field) {
+			return nil, fmt.Errorf("%w: unknown field %q, expected one of %s",
+				ErrInvalidQuery, field, strings.Join(QueryFields(), ", "))
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%w: no value given for %s", ErrInvalidQuery, field)
+		}
+
+		t := term{field: field, value: strings.ToLower(value)}
+		if isNumeric(field) {
+			if t.min, t.max, err = parseRange(value); err != nil {
+				return nil, fmt.Errorf("%w: %s must be a number or a range such as 1970-1979, got %q",
+					ErrInvalidQuery, field, value)
+			}
+		}
+		q.terms = append(q.terms, t)
+	}
+
+	return q, nil
+}
+
+// splitQuery splits the query into words at spaces outside of double quotes,
+// which are removed.
+func splitQuery(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case unicode.IsSpace(r) && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func isNumeric(field string) bool {
+	return field == FieldYear || field == FieldTrack || field == FieldDisc || field == FieldDuration
+}
+
+// parseRange parses a number or a range of the form min-max.
+func parseRange(value string) (int, int, error) {
+	lo, hi, found := strings.Cut(value, "-")
+	min, err := strconv.Atoi(lo)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return min, min, nil
+	}
+	max, err := strconv.Atoi(hi)
+	if err != nil || max < min {
+		return 0, 0, fmt.Errorf("invalid range")
+	}
+
+	return min, max, nil
+}
+
+// Match reports whether the track meets every term of the query.
+func (q *Query) Match(t *Track) bool {
+	for _, term := range q.terms {
+		if !term.match(t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (tm term) match(t *Track) bool {
+	if tm.field == "" {
+		for _, field := range textFields {
+			if strings.Contains(strings.ToLower(t.text(field)), tm.value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if isNumeric(tm.field) {
+		n := t.number(tm.field)
+		return n >= tm.min && n <= tm.max
+	}
+
+	return strings.Contains(strings.ToLower(t.text(tm.field)), tm.value)
+}
+
+// text returns the value of a text field of the track.
+func (t *Track) text(field string) string {
+	switch field {
+	case FieldTitle:
+		return t.Prop.Title
+	case FieldArtist:
+		return t.Prop.Artist
+	case FieldAlbum:
+		return t.Prop.Album
+	case FieldAlbumArtist:
+		return t.Prop.AlbumArtist
+	case FieldComposer:
+		return t.Prop.Composer
+	case FieldGenre:
+		return t.Prop.Genre
+	case FieldComment:
+		return t.Prop.Comment
+	case FieldPath:
+		return t.Path
+	case FieldFileName:
+		return filepath.Base(t.Path)
+	}
+
+	return ""
+}
+
+// number returns the value of a numeric field of the track, zero if unknown.
+// The duration is given in seconds.
+func (t *Track) number(field string) int {
+	switch field {
+	case FieldYear:
+		return t.Prop.Year
+	case FieldTrack:
+		return t.Prop.Track
+	case FieldDisc:
+		return t.Prop.Disc
+	case FieldDuration:
+		return int(t.Prop.Duration.Seconds())
+	}
+
+	return 0
+}
@@ -0,0 +1,106 @@
+package library
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"scythix/playlist"
+)
+
+// testLibrary returns a library of tracks without files.
+func testLibrary() *Library {
+	tracks := []*Track{
+		{Path: "/music/bowie/low/01.mp3", Prop: playlist.AudioProperties{
+			Title: "Speed of Life", Artist: "David Bowie", Album: "Low", Genre: "Rock", Year: 1977, Track: 1,
+			Duration: 166 * time.Second}},
+		{Path: "/music/bowie/low/02.mp3", Prop: playlist.AudioProperties{
+			Title: "Breaking Glass", Artist: "David Bowie", Album: "Low", Genre: "Rock", Year: 1977, Track: 2,
+			Duration: 112 * time.Second}},
+		{Path: "/music/davis/kind of blue/01.flac", Prop: playlist.AudioProperties{
+			Title: "So What", Artist: "Miles Davis", Album: "Kind of Blue", Genre: "Jazz", Year: 1959, Track: 1,
+			Duration: 562 * time.Second}},
+		{Path: "/music/davis/kind of blue/02.flac", Prop: playlist.AudioProperties{
+			Title: "Freddie Freeloader", Artist: "Miles Davis", Album: "Kind of Blue", Genre: "Jazz", Year: 1959,
+			Track: 2, Duration: 586 * time.Second}},
+		{Path: "/music/other/low.mp3", Prop: playlist.AudioProperties{
+			Title: "Low", Artist: "Other Band", Album: "Low", Genre: "Pop", Year: 2001, Track: 1,
+			Duration: 200 * time.Second}},
+		{Path: "/music/untagged.mp3"},
+	}
+
+	l := &Library{Tracks: map[string]*Track{}}
+	for _, t := range tracks {
+		l.Tracks[t.Path] = t
+	}
+
+	return l
+}
+
+func titles(tracks []*Track) []string {
+	var titles []string
+	for _, t := range tracks {
+		titles = append(titles, t.Prop.Title)
+	}
+
+	return titles
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr error
+	}{
+		{query: "glass", want: []string{"Breaking Glass"}},
+		{query: "bowie low", want: []string{"Speed of Life", "Breaking Glass"}},
+		{query: `artist:"miles davis" track:2`, want: []string{"Freddie Freeloader"}},
+		{query: "year:1950-1979 genre:jazz", want: []string{"So What", "Freddie Freeloader"}},
+		{query: "duration:500-600", want: []string{"So What", "Freddie Freeloader"}},
+		{query: "filename:untagged", want: []string{""}},
+		{query: "ARTIST:other", want: []string{"Low"}},
+		{query: "nothing", want: nil},
+		{query: "", wantErr: ErrInvalidQuery},
+		{query: "   ", wantErr: ErrInvalidQuery},
+		{query: "mood:happy", wantErr: ErrInvalidQuery},
+		{query: "artist:", wantErr: ErrInvalidQuery},
+		{query: "year:197x", wantErr: ErrInvalidQuery},
+		{query: "year:1979-1970", wantErr: ErrInvalidQuery},
+		{query: `artist:"david`, wantErr: ErrInvalidQuery},
+	}
+
+	l := testLibrary()
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParseQuery(%q) error = %v, want %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := titles(l.Search(q)); !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestInDir(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		want      bool
+	}{
+		{"/music/a.mp3", "/music", true},
+		{"/music/a.mp3", "/music/", true},
+		{"/music/sub/a.mp3", "/music", true},
+		{"/musical/a.mp3", "/music", false},
+		{"/music", "/music", false},
+	}
+
+	for _, tt := range tests {
+		if got := InDir(tt.path, tt.dir); got != tt.want {
+			t.Errorf("InDir(%q, %q) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
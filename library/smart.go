@@ -0,0 +1,401 @@
+package library
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SmartQuery selects, orders and limits tracks of the library, e.g.
+//
+//	genre = "jazz" AND year < 1970 ORDER BY random LIMIT 50
+//
+// Conditions compare a field with a value using =, !=, <, <=, >, >= or ~ (contains),
+// and are combined with AND, OR, NOT and parentheses. Text comparisons ignore case.
+// ORDER BY takes fields followed by ASC or DESC, or random. Keywords are case-insensitive.
+type SmartQuery struct {
+	cond   condition // nil to select every track
+	order  []orderKey
+	random bool
+	limit  int // 0 for no limit
+}
+
+// condition is a boolean expression over the fields of a track.
+type condition interface {
+	match(t *Track) bool
+}
+
+type andCond struct{ left, right condition }
+type orCond struct{ left, right condition }
+type notCond struct{ cond condition }
+
+// compareCond compares a field of the track with a value.
+type compareCond struct {
+	field string
+	op    string
+	text  string // lower case
+	num   int
+}
+
+// orderKey is a field of the ORDER BY clause.
+type orderKey struct {
+	field string
+	desc  bool
+}
+
+func (c andCond) match(t *Track) bool { return c.left.match(t) && c.right.match(t) }
+func (c orCond) match(t *Track) bool  { return c.left.match(t) || c.right.match(t) }
+func (c notCond) match(t *Track) bool { return !c.cond.match(t) }
+
+func (c compareCond) match(t *Track) bool {
+	var r int
+	if isNumeric(c.field) {
+		r = cmp.Compare(t.number(c.field), c.num)
+	} else {
+		value := strings.ToLower(t.text(c.field))
+		if c.op == "~" {
+			return strings.Contains(value, c.text)
+		}
+		r = strings.Compare(value, c.text)
+	}
+
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	}
+
+	return false
+}
+
+// Resolve returns the tracks of the library selected by the query, in its order.
+// Without ORDER BY, the tracks are ordered as by Sorted.
+func (l *Library) Resolve(q *SmartQuery) []*Track {
+	var tracks []*Track
+	for _, t := range l.Sorted() {
+		if q.cond == nil || q.cond.match(t) {
+			tracks = append(tracks, t)
+		}
+	}
+
+	switch {
+	case q.random:
+		rand.Shuffle(len(tracks), func(i, j int) { tracks[i], tracks[j] = tracks[j], tracks[i] })
+	case len(q.order) > 0:
+		slices.SortStableFunc(tracks, func(a, b *Track) int {
+			for _, key := range q.order {
+				var r int
+				if isNumeric(key.field) {
+					r = cmp.Compare(a.number(key.field), b.number(key.field))
+				} else {
+					r = cmp.Compare(strings.ToLower(a.text(key.field)), strings.ToLower(b.text(key.field)))
+				}
+				if key.desc {
+					r = -r
+				}
+				if r != 0 {
+					return r
+				}
+			}
+			return 0
+		})
+	}
+
+	if q.limit > 0 && len(tracks) > q.limit {
+		tracks = tracks[:q.limit]
+	}
+
+	return tracks
+}
+
+// token is a word, a quoted string, an operator or a parenthesis of a smart query.
+type token struct {
+	text   string
+	quoted bool
+}
+
+// smartParser parses the tokens of a smart query by recursive descent.
+type smartParser struct {
+	tokens []token
+	pos    int
+}
+
+// ParseSmart parses a smart query. An empty query selects every track.
+func ParseSmart(s string) (*SmartQuery, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &smartParser{tokens: tokens}
+	q := &SmartQuery{}
+	if !p.atEnd() && !p.keyword("ORDER") && !p.keyword("LIMIT") {
+		if q.cond, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("ORDER") {
+		p.pos++
+		if !p.keyword("BY") {
+			return nil, p.errorf("expected BY after ORDER")
+		}
+		p.pos++
+		if err := p.parseOrder(q); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("LIMIT") {
+		p.pos++
+		tok, ok := p.next()
+		n, err := strconv.Atoi(tok.text)
+		if !ok || tok.quoted || err != nil || n < 1 {
+			return nil, fmt.Errorf("%w: LIMIT must be followed by a positive number", ErrInvalidQuery)
+		}
+		q.limit = n
+	}
+	if !p.atEnd() {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+
+	return q, nil
+}
+
+// SmartExt is the extension of the files holding a smart query.
+const SmartExt = ".smart"
+
+// ReadSmartFile returns the smart query of the file. Lines starting with # are
+// comments, the other lines are joined.
+func ReadSmartFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, " "), nil
+}
+
+// tokenize splits the query into tokens. Strings are quoted with double or single quotes.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			end := slices.Index(runes[i+1:], r)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+			}
+			tokens = append(tokens, token{text: string(runes[i+1 : i+1+end]), quoted: true})
+			i += end + 2
+		case strings.ContainsRune("()~,", r):
+			tokens = append(tokens, token{text: string(r)})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("%w: unknown operator !, expected !=", ErrInvalidQuery)
+			}
+			tokens = append(tokens, token{text: op})
+			i += len(op)
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()~,=!<>\"'", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: string(runes[start:i])})
+		}
+	}
+
+	return tokens, nil
+}
+
+func (p *smartParser) atEnd() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// next returns the current token and moves to the following one.
+func (p *smartParser) next() (token, bool) {
+	if p.atEnd() {
+		return token{}, false
+	}
+	p.pos++
+
+	return p.tokens[p.pos-1], true
+}
+
+// keyword reports whether the current token is the keyword, ignoring case.
+func (p *smartParser) keyword(kw string) bool {
+	return !p.atEnd() && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, kw)
+}
+
+func (p *smartParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidQuery}, args...)...)
+}
+
+// parseOr parses conditions separated by OR.
+func (p *smartParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCond{left, right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses conditions separated by AND, which binds more tightly than OR.
+func (p *smartParser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCond{left, right}
+	}
+
+	return left, nil
+}
+
+// parseNot parses a comparison or a parenthesized condition, optionally negated.
+func (p *smartParser) parseNot() (condition, error) {
+	if p.keyword("NOT") {
+		p.pos++
+		cond, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCond{cond}, nil
+	}
+
+	if !p.atEnd() && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == "(" {
+		p.pos++
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, ok := p.next(); !ok || tok.quoted || tok.text != ")" {
+			return nil, p.errorf("missing )")
+		}
+		return cond, nil
+	}
+
+	return p.parseComparison()
+}
+
+// parseComparison parses a comparison of the form field op value.
+func (p *smartParser) parseComparison() (condition, error) {
+	tok, ok := p.next()
+	if !ok {
+		return nil, p.errorf("expected a condition")
+	}
+	field, err := smartField(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.next()
+	if !ok || op.quoted || !slices.Contains([]string{"=", "!=", "<", "<=", ">", ">=", "~"}, op.text) {
+		return nil, p.errorf("expected an operator after %s: =, !=, <, <=, >, >= or ~", field)
+	}
+
+	value, ok := p.next()
+	if !ok || (!value.quoted && strings.ContainsAny(value.text, "()~,=!<>")) {
+		return nil, p.errorf("expected a value after %s %s", field, op.text)
+	}
+
+	c := compareCond{field: field, op: op.text, text: strings.ToLower(value.text)}
+	if isNumeric(field) {
+		if op.text == "~" {
+			return nil, p.errorf("%s is a number, ~ only applies to text", field)
+		}
+		if c.num, err = strconv.Atoi(value.text); err != nil {
+			return nil, p.errorf("%s must be compared with a number, got %q", field, value.text)
+		}
+	}
+
+	return c, nil
+}
+
+// parseOrder parses the fields of the ORDER BY clause.
+func (p *smartParser) parseOrder(q *SmartQuery) error {
+	if p.keyword("RANDOM") {
+		p.pos++
+		q.random = true
+		return nil
+	}
+
+	for {
+		tok, ok := p.next()
+		if !ok {
+			return p.errorf("expected a field or random after ORDER BY")
+		}
+		field, err := smartField(tok)
+		if err != nil {
+			return err
+		}
+		key := orderKey{field: field}
+		switch {
+		case p.keyword("DESC"):
+			key.desc = true
+			p.pos++
+		case p.keyword("ASC"):
+			p.pos++
+		}
+		q.order = append(q.order, key)
+
+		if p.atEnd() || p.tokens[p.pos].quoted || p.tokens[p.pos].text != "," {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+// smartField returns the field named by the token.
+func smartField(tok token) (string, error) {
+	field := strings.ToLower(tok.text)
+	if tok.quoted || !slices.Contains(QueryFields(), field) {
+		return "", fmt.Errorf("%w: unknown field %q, expected one of %s",
+			ErrInvalidQuery, tok.text, strings.Join(QueryFields(), ", "))
+	}
+
+	return field, nil
+}
@@ -0,0 +1,68 @@
+package library
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseSmart(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr error
+	}{
+		{query: `genre = "jazz"`, want: []string{"So What", "Freddie Freeloader"}},
+		{query: `genre = 'ROCK' AND title ~ "life"`, want: []string{"Speed of Life"}},
+		// Unknown numbers are zero.
+		{query: `year < 1970 OR artist = "other band"`, want: []string{"", "So What", "Freddie Freeloader", "Low"}},
+		{query: `NOT (genre = rock OR genre = jazz) AND title != ""`, want: []string{"Low"}},
+		{query: `album = low ORDER BY year DESC, track`, want: []string{"Low", "Speed of Life", "Breaking Glass"}},
+		{query: `genre = jazz ORDER BY duration desc LIMIT 1`, want: []string{"Freddie Freeloader"}},
+		{query: `year >= 1977 AND year <= 1977 order by title`, want: []string{"Breaking Glass", "Speed of Life"}},
+		{query: `title > "r" LIMIT 2`, want: []string{"Speed of Life", "So What"}},
+		{query: `LIMIT 1`, want: []string{""}},
+		{query: `year = 1977 ORDER BY random`, want: []string{"Breaking Glass", "Speed of Life"}},
+		{query: `mood = happy`, wantErr: ErrInvalidQuery},
+		{query: `genre jazz`, wantErr: ErrInvalidQuery},
+		{query: `genre =`, wantErr: ErrInvalidQuery},
+		{query: `year ~ 19`, wantErr: ErrInvalidQuery},
+		{query: `year = later`, wantErr: ErrInvalidQuery},
+		{query: `(genre = jazz`, wantErr: ErrInvalidQuery},
+		{query: `genre = "jazz`, wantErr: ErrInvalidQuery},
+		{query: `genre ! jazz`, wantErr: ErrInvalidQuery},
+		{query: `ORDER year`, wantErr: ErrInvalidQuery},
+		{query: `LIMIT 0`, wantErr: ErrInvalidQuery},
+		{query: `genre = jazz extra`, wantErr: ErrInvalidQuery},
+	}
+
+	l := testLibrary()
+	for _, tt := range tests {
+		q, err := ParseSmart(tt.query)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParseSmart(%q) error = %v, want %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		got := titles(l.Resolve(q))
+		if q.random {
+			slices.Sort(got)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestResolveEmpty(t *testing.T) {
+	q, err := ParseSmart("")
+	if err != nil {
+		t.Fatalf("ParseSmart() error = %v", err)
+	}
+	l := testLibrary()
+	if got := l.Resolve(q); len(got) != len(l.Tracks) {
+		t.Errorf("Resolve() of the empty query = %d tracks, want %d", len(got), len(l.Tracks))
+	}
+}
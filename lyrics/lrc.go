@@ -0,0 +1,108 @@
+package lyrics
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// timeTag matches a time tag of the LRC format, e.g. [01:23.45].
+	timeTag = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	// idTag matches an ID tag of the LRC format, e.g. [ar:Artist] or [offset:+200].
+	idTag = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]$`)
+	// wordTag matches the time tags of single words of the enhanced LRC format, e.g. <01:23.45>.
+	wordTag = regexp.MustCompile(`<\d+:\d{1,2}(?:[.:]\d{1,3})?>`)
+)
+
+// Parse reads lyrics in the LRC format. Every line starts with the positions it
+// is sung at, e.g. "[01:23.45]text", and the offset tag shifts all of them.
+// Text without time tags is returned as unsynchronized lyrics.
+func Parse(text string) *Lyrics {
+	var (
+		l      Lyrics
+		plain  []Line
+		offset time.Duration
+	)
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		raw = strings.TrimSpace(raw)
+
+		var times []time.Duration
+		rest := raw
+		for {
+			m := timeTag.FindStringSubmatch(rest)
+			if m == nil {
+				break
+			}
+			times = append(times, parseTimeTag(m))
+			rest = rest[len(m[0]):]
+		}
+		rest = strings.TrimSpace(wordTag.ReplaceAllString(rest, ""))
+
+		if len(times) == 0 {
+			if m := idTag.FindStringSubmatch(raw); m != nil {
+				if strings.EqualFold(m[1], "offset") {
+					if ms, err := strconv.Atoi(strings.TrimSpace(m[2])); err == nil {
+						offset = time.Duration(ms) * time.Millisecond
+					}
+				}
+				continue
+			}
+			plain = append(plain, Line{Text: rest})
+			continue
+		}
+		for _, t := range times {
+			l.Lines = append(l.Lines, Line{Time: t, Text: rest})
+		}
+	}
+
+	if len(l.Lines) == 0 {
+		l.Lines = trimEmpty(plain)
+		return &l
+	}
+
+	// A positive offset makes the lines appear sooner.
+	l.Synced = true
+	for i := range l.Lines {
+		l.Lines[i].Time = max(0, l.Lines[i].Time-offset)
+	}
+	slices.SortStableFunc(l.Lines, func(a, b Line) int { return cmp.Compare(a.Time, b.Time) })
+
+	return &l
+}
+
+// parseTimeTag returns the position of a time tag matched by timeTag.
+func parseTimeTag(m []string) time.Duration {
+	minutes, _ := strconv.Atoi(m[1])
+	seconds, _ := strconv.Atoi(m[2])
+	d := time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	if m[3] != "" {
+		// The fraction is given in hundredths of a second, or in milliseconds.
+		frac, _ := strconv.Atoi(m[3])
+		switch len(m[3]) {
+		case 1:
+			d += time.Duration(frac) * 100 * time.Millisecond
+		case 2:
+			d += time.Duration(frac) * 10 * time.Millisecond
+		default:
+			d += time.Duration(frac) * time.Millisecond
+		}
+	}
+
+	return d
+}
+
+// trimEmpty removes the empty lines at the start and the end of the lyrics.
+func trimEmpty(lines []Line) []Line {
+	for len(lines) > 0 && lines[0].Text == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1].Text == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,143 @@
+// Package lyrics finds and parses the lyrics of audio files: sidecar .lrc files
+// and lyrics embedded in the tags, either synchronized with the playback
+// position or as plain text.
+package lyrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+var ErrNoLyrics = fmt.Errorf("no lyrics found")
+
+// Line is a line of the lyrics.
+type Line struct {
+	// Time is the playback position the line is sung at. It is zero for all
+	// lines of unsynchronized lyrics.
+	Time time.Duration
+	Text string
+}
+
+// Lyrics holds the lyrics of a track.
+type Lyrics struct {
+	Lines []Line
+	// Synced is set if the lines carry the positions they are sung at.
+	Synced bool
+	// Source is the path of the .lrc file, or the name of the tag the lyrics
+	// were read from, e.g. "SYLT".
+	Source string
+}
+
+// Text returns the lyrics as plain text, one line per line.
+func (l *Lyrics) Text() string {
+	lines := make([]string, 0, len(l.Lines))
+	for _, line := range l.Lines {
+		lines = append(lines, line.Text)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// At returns the index of the line sung at the playback position, or -1 if the
+// position precedes the first line or the lyrics are not synchronized.
+func (l *Lyrics) At(pos time.Duration) int {
+	if !l.Synced {
+		return -1
+	}
+
+	return sort.Search(len(l.Lines), func(i int) bool { return l.Lines[i].Time > pos }) - 1
+}
+
+// Load returns the lyrics of the audio file. A sidecar .lrc file with the same
+// base name takes precedence over the tags. Of the tags, synchronized lyrics
+// (ID3v2 SYLT, or SLT of ID3v2.2) are preferred to the text of USLT or the Vorbis LYRICS comment,
+// which may itself be in the LRC format. ErrNoLyrics is returned if there are none.
+func Load(trackPath string) (*Lyrics, error) {
+	if l, err := loadSidecar(trackPath); err == nil {
+		return l, nil
+	}
+
+	f, err := os.Open(trackPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := tag.ReadFrom(f)
+	if err != nil {
+		return nil, ErrNoLyrics
+	}
+	// ID3v2.2 names the frame SLT, its content is the same.
+	for _, name := range []string{"SYLT", "SLT"} {
+		b, ok := m.Raw()[name].([]byte)
+		if !ok {
+			continue
+		}
+		if lines, err := parseSYLT(b); err == nil && len(lines) > 0 {
+			return &Lyrics{Lines: lines, Synced: true, Source: name}, nil
+		}
+	}
+
+	text := m.Lyrics()
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrNoLyrics
+	}
+	source := "LYRICS"
+	if m.Format() == tag.ID3v2_2 || m.Format() == tag.ID3v2_3 || m.Format() == tag.ID3v2_4 {
+		source = "USLT"
+	}
+	l := Parse(text)
+	l.Source = source
+
+	return l, nil
+}
+
+// Sidecar returns the path of the .lrc file next to the audio file, with the
+// same base name and an extension matched regardless of case, and its
+// modification time. The path is empty if there is no such file.
+func Sidecar(trackPath string) (string, time.Time) {
+	dir := filepath.Dir(trackPath)
+	name := filepath.Base(trackPath)
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", time.Time{}
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !strings.EqualFold(ext, ".lrc") || strings.TrimSuffix(entry.Name(), ext) != base {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		return path, info.ModTime()
+	}
+
+	return "", time.Time{}
+}
+
+// loadSidecar reads the .lrc file next to the audio file.
+func loadSidecar(trackPath string) (*Lyrics, error) {
+	path, _ := Sidecar(trackPath)
+	if path == "" {
+		return nil, ErrNoLyrics
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := Parse(string(b))
+	l.Source = path
+
+	return l, nil
+}
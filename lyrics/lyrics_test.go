@@ -0,0 +1,233 @@
+package lyrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"scythix/id3"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Lyrics
+	}{
+		{
+			name: "synchronized",
+			text: "[ar:Artist]\n[00:01.00]One\n[00:02.50]Two\n",
+			want: Lyrics{Synced: true, Lines: []Line{{time.Second, "One"}, {2500 * time.Millisecond, "Two"}}},
+		},
+		{
+			name: "fractions",
+			text: "[00:01.5]a\r\n[00:01.05]b\r\n[00:01.005]c\r\n[01:02]d",
+			want: Lyrics{Synced: true, Lines: []Line{
+				{1005 * time.Millisecond, "c"}, {1050 * time.Millisecond, "b"},
+				{1500 * time.Millisecond, "a"}, {62 * time.Second, "d"},
+			}},
+		},
+		{
+			name: "repeated line",
+			text: "[00:10.00][00:01.00]Chorus\n[00:05.00]Verse",
+			want: Lyrics{Synced: true, Lines: []Line{
+				{time.Second, "Chorus"}, {5 * time.Second, "Verse"}, {10 * time.Second, "Chorus"},
+			}},
+		},
+		{
+			name: "offset",
+			text: "[offset:+500]\n[00:00.20]a\n[00:02.00]b",
+			want: Lyrics{Synced: true, Lines: []Line{{0, "a"}, {1500 * time.Millisecond, "b"}}},
+		},
+		{
+			name: "word tags",
+			text: "[00:01.00]<00:01.00>One <00:01.50>two",
+			want: Lyrics{Synced: true, Lines: []Line{{time.Second, "One two"}}},
+		},
+		{
+			name: "plain",
+			text: "\nFirst line\n\nSecond line\n\n",
+			want: Lyrics{Lines: []Line{{0, "First line"}, {0, ""}, {0, "Second line"}}},
+		},
+		{
+			name: "empty",
+			text: "",
+			want: Lyrics{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.text)
+			if got.Synced != tt.want.Synced || !equalLines(got.Lines, tt.want.Lines) {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func equalLines(a, b []Line) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
+
+func TestLyricsAt(t *testing.T) {
+	l := &Lyrics{Synced: true, Lines: []Line{{time.Second, "a"}, {3 * time.Second, "b"}}}
+	tests := []struct {
+		pos  time.Duration
+		want int
+	}{
+		{0, -1},
+		{time.Second, 0},
+		{2 * time.Second, 0},
+		{3 * time.Second, 1},
+		{time.Hour, 1},
+	}
+
+	for _, tt := range tests {
+		if got := l.At(tt.pos); got != tt.want {
+			t.Errorf("At(%v) = %d, want %d", tt.pos, got, tt.want)
+		}
+	}
+
+	plain := &Lyrics{Lines: []Line{{0, "a"}}}
+	if got := plain.At(time.Second); got != -1 {
+		t.Errorf("At() of unsynchronized lyrics = %d, want -1", got)
+	}
+}
+
+// syltLine is a line of a SYLT frame, with the encoded text and its terminator.
+type syltLine struct {
+	text []byte
+	ms   uint32
+}
+
+// sylt returns the body of a SYLT frame with positions in milliseconds.
+func sylt(enc byte, descriptor []byte, lines ...syltLine) []byte {
+	b := append([]byte{enc, 'e', 'n', 'g', syltMilliseconds, 1}, descriptor...)
+	for _, l := range lines {
+		b = append(b, l.text...)
+		b = binary.BigEndian.AppendUint32(b, l.ms)
+	}
+
+	return b
+}
+
+func TestParseSYLT(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   []byte
+		want    []Line
+		wantErr error
+	}{
+		{
+			name:  "ISO-8859-1",
+			frame: sylt(id3.EncodingISO88591, []byte{0}, syltLine{[]byte("Caf\xe9\x00"), 1000}, syltLine{[]byte("\nNext\x00"), 2500}),
+			want:  []Line{{time.Second, "Café"}, {2500 * time.Millisecond, "Next"}},
+		},
+		{
+			name:  "UTF-8",
+			frame: sylt(id3.EncodingUTF8, []byte("desc\x00"), syltLine{[]byte("Привет\x00"), 10}),
+			want:  []Line{{10 * time.Millisecond, "Привет"}},
+		},
+		{
+			name: "UTF-16 with byte order mark",
+			frame: sylt(id3.EncodingUTF16, []byte{0xff, 0xfe, 0, 0},
+				syltLine{[]byte{0xff, 0xfe, 'H', 0, 'i', 0, 0, 0}, 300}),
+			want: []Line{{300 * time.Millisecond, "Hi"}},
+		},
+		{
+			name:  "UTF-16BE",
+			frame: sylt(id3.EncodingUTF16BE, []byte{0, 0}, syltLine{[]byte{0, 'O', 0, 'k', 0, 0}, 42}),
+			want:  []Line{{42 * time.Millisecond, "Ok"}},
+		},
+		{
+			name:    "MPEG frames",
+			frame:   []byte{id3.EncodingUTF8, 'e', 'n', 'g', 1, 1, 0, 'a', 0, 0, 0, 0, 1},
+			wantErr: ErrInvalidSYLT,
+		},
+		{
+			name:    "missing position",
+			frame:   append(sylt(id3.EncodingUTF8, []byte{0}), "Line\x00\x00\x01"...),
+			wantErr: ErrInvalidSYLT,
+		},
+		{
+			name:    "unterminated text",
+			frame:   append(sylt(id3.EncodingUTF8, []byte{0}), "Line"...),
+			wantErr: ErrInvalidSYLT,
+		},
+		{
+			name:    "truncated",
+			frame:   []byte{id3.EncodingUTF8, 'e', 'n', 'g'},
+			wantErr: ErrInvalidSYLT,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSYLT(tt.frame)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseSYLT() error = %v, want %v", err, tt.wantErr)
+			}
+			if !equalLines(got, tt.want) {
+				t.Errorf("parseSYLT() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// id3v22 returns an ID3v2.2 tag holding the frame.
+func id3v22(id string, body []byte) []byte {
+	frame := append([]byte(id), byte(len(body)>>16), byte(len(body)>>8), byte(len(body)))
+	frame = append(frame, body...)
+	n := len(frame)
+	header := []byte{'I', 'D', '3', 2, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+
+	return append(header, frame...)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	track := filepath.Join(dir, "song.mp3")
+	tag := id3v22("SLT", sylt(id3.EncodingISO88591, []byte{0}, syltLine{[]byte("Tagged\x00"), 1000}))
+	if err := os.WriteFile(track, append(tag, make([]byte, 128)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Load(track)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []Line{{time.Second, "Tagged"}}
+	if !l.Synced || l.Source != "SLT" || !equalLines(l.Lines, want) {
+		t.Errorf("Load() = %+v, want the lines of the SLT frame", *l)
+	}
+
+	// A sidecar file takes precedence, whatever the case of its extension.
+	sidecar := filepath.Join(dir, "song.Lrc")
+	if err := os.WriteFile(sidecar, []byte("[00:02.00]Sidecar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "song2.lrc"), []byte("[00:03.00]Other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Load(track)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want = []Line{{2 * time.Second, "Sidecar"}}
+	if l.Source != sidecar || !equalLines(l.Lines, want) {
+		t.Errorf("Load() = %+v, want the lines of %s", *l, sidecar)
+	}
+	if path, modTime := Sidecar(track); path != sidecar || modTime.IsZero() {
+		t.Errorf("Sidecar() = %q, %v, want %q", path, modTime, sidecar)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.mp3")); err == nil {
+		t.Error("Load() of a missing track succeeded")
+	}
+}
@@ -0,0 +1,107 @@
+package lyrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"scythix/id3"
+)
+
+var ErrInvalidSYLT = fmt.Errorf("invalid SYLT frame")
+
+// syltMilliseconds is the SYLT time stamp format of positions in milliseconds.
+// The other format, MPEG frames, is not supported.
+const syltMilliseconds = 2
+
+// parseSYLT reads the lines of an ID3v2 SYLT (synchronized lyrics) frame:
+// the text encoding, the language, the time stamp format, the content type and
+// a description, followed by the lines, each terminated by its position.
+func parseSYLT(b []byte) ([]Line, error) {
+	if len(b) < 6 {
+		return nil, ErrInvalidSYLT
+	}
+	enc, format := b[0], b[4]
+	if format != syltMilliseconds {
+		return nil, fmt.Errorf("%w: unsupported time stamp format %d", ErrInvalidSYLT, format)
+	}
+
+	// Skip the content descriptor.
+	_, b, ok := cutText(b[6:], enc)
+	if !ok {
+		return nil, ErrInvalidSYLT
+	}
+
+	var lines []Line
+	for len(b) > 0 {
+		var text string
+		text, b, ok = cutText(b, enc)
+		if !ok || len(b) < 4 {
+			return nil, ErrInvalidSYLT
+		}
+		ms := binary.BigEndian.Uint32(b)
+		b = b[4:]
+
+		// Lines usually start with a line feed, which separates them from the previous one.
+		text = strings.Trim(text, "\r\n")
+		lines = append(lines, Line{Time: time.Duration(ms) * time.Millisecond, Text: text})
+	}
+
+	return lines, nil
+}
+
+// cutText decodes the null-terminated string at the start of b in the given
+// encoding. It returns the string and the bytes following the terminator.
+func cutText(b []byte, enc byte) (string, []byte, bool) {
+	switch enc {
+	case id3.EncodingISO88591, id3.EncodingUTF8:
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			return "", nil, false
+		}
+		if enc == id3.EncodingUTF8 {
+			return string(b[:i]), b[i+1:], true
+		}
+		runes := make([]rune, i)
+		for j, c := range b[:i] {
+			runes[j] = rune(c)
+		}
+		return string(runes), b[i+1:], true
+
+	case id3.EncodingUTF16, id3.EncodingUTF16BE:
+		// The terminator is a pair of zero bytes at an even position.
+		i := 0
+		for ; i+1 < len(b) && (b[i] != 0 || b[i+1] != 0); i += 2 {
+		}
+		if i+1 >= len(b) {
+			return "", nil, false
+		}
+		return decodeUTF16(b[:i], enc == id3.EncodingUTF16BE), b[i+2:], true
+	}
+
+	return "", nil, false
+}
+
+// decodeUTF16 decodes UTF-16 text, whose byte order is given by the byte order mark
+// unless bigEndian is set.
+func decodeUTF16(b []byte, bigEndian bool) string {
+	var order binary.ByteOrder = binary.BigEndian
+	if !bigEndian && len(b) >= 2 {
+		switch {
+		case b[0] == 0xff && b[1] == 0xfe:
+			order, b = binary.LittleEndian, b[2:]
+		case b[0] == 0xfe && b[1] == 0xff:
+			b = b[2:]
+		}
+	}
+
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, order.Uint16(b[i:]))
+	}
+
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,305 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Exit codes of the command line interface.
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitNotRunning = 3
+)
+
+var (
+	ErrUsage          = fmt.Errorf("invalid usage")
+	ErrNotRunning     = fmt.Errorf("player is not running")
+	ErrUnknownCommand = fmt.Errorf("unknown command")
+)
+
+// command is a subcommand of the command line interface, e.g. "play" or "next".
+type command struct {
+	name    string
+	args    string // synopsis of the positional arguments
+	summary string
+
+	// minArgs and maxArgs limit the number of positional arguments,
+	// maxArgs is negative if the number is unlimited.
+	minArgs int
+	maxArgs int
+
+	// untimed commands are not limited by callTimeout as a whole,
+	// e.g. because they issue a call per file.
+	untimed bool
+
+	// complete is the kind of the positional arguments offered by shell completion.
+	complete string
+
+	flags *flag.FlagSet
+	run   func(ctx context.Context, args []string) error
+}
+
+// newCommand returns a command without flags. Flags are defined on cmd.flags
+// before the command is run.
+func newCommand(name, args, summary string, minArgs, maxArgs int) *command {
+	cmd := &command{
+		name:    name,
+		args:    args,
+		summary: summary,
+		minArgs: minArgs,
+		maxArgs: maxArgs,
+		flags:   flag.NewFlagSet(name, flag.ContinueOnError),
+	}
+	cmd.flags.Usage = func() { cmd.usage(cmd.flags.Output()) }
+
+	return cmd
+}
+
+// usage prints the synopsis of the command and its flags.
+func (c *command) usage(w io.Writer) {
+	synopsis := "scythix " + c.name
+	if hasFlags(c.flags) {
+		synopsis += " [flags]"
+	}
+	if c.args != "" {
+		synopsis += " " + c.args
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", synopsis, c.summary)
+
+	if hasFlags(c.flags) {
+		fmt.Fprintln(w, "\nFlags:")
+		c.flags.SetOutput(w)
+		c.flags.PrintDefaults()
+	}
+}
+
+// hasFlags reports whether any flags are defined in the flag set.
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+
+	return found
+}
+
+// execute parses the arguments of the command, runs it and returns the exit code.
+func (c *command) execute(ctx context.Context, args []string) int {
+	// Commands without flags take their arguments as they are, so that
+	// e.g. "seek -10" isn't mistaken for a flag.
+	if hasFlags(c.flags) || isHelp(args) {
+		c.flags.SetOutput(os.Stderr)
+		if err := c.flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return exitOK
+			}
+			return exitUsage
+		}
+		args = c.flags.Args()
+	}
+
+	if len(args) < c.minArgs || (c.maxArgs >= 0 && len(args) > c.maxArgs) {
+		fmt.Fprintf(os.Stderr, "scythix %s: wrong number of arguments\n", c.name)
+		c.usage(os.Stderr)
+		return exitUsage
+	}
+
+	if !c.untimed {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callTimeout)
+		defer cancel()
+	}
+
+	err := c.run(ctx, args)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, ErrUsage):
+		fmt.Fprintf(os.Stderr, "scythix %s: %v\n", c.name, err)
+		c.usage(os.Stderr)
+		return exitUsage
+	case errors.Is(err, ErrNotRunning):
+		log.Debug("Player server not running")
+		fmt.Fprintf(os.Stderr, "scythix %s: %v\n", c.name, err)
+		return exitNotRunning
+	default:
+		log.Error(err)
+		fmt.Fprintf(os.Stderr, "scythix %s: %v\n", c.name, err)
+		return exitError
+	}
+}
+
+// isHelp reports whether the arguments ask for help with a command.
+func isHelp(args []string) bool {
+	return len(args) > 0 && slices.Contains([]string{"-h", "-help", "--help"}, args[0])
+}
+
+// findCommand returns the command with the given name.
+func findCommand(cmds []*command, name string) (*command, bool) {
+	for _, cmd := range cmds {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+
+	return nil, false
+}
+
+// legacyFlag is a flag of the former command line interface, e.g. -play PATH or -next.
+// It is translated to the equivalent command, or passed on to the command as its
+// flag if option is set.
+type legacyFlag struct {
+	name    string
+	command string
+	args    []string // arguments passed to the command before the flag value
+
+	hasValue bool
+	option   bool
+
+	set   bool
+	value string
+}
+
+func (f *legacyFlag) String() string {
+	return f.value
+}
+
+func (f *legacyFlag) Set(s string) error {
+	if !f.hasValue {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.set = v
+		return nil
+	}
+	f.set = true
+	f.value = s
+
+	return nil
+}
+
+func (f *legacyFlag) IsBoolFlag() bool {
+	return !f.hasValue
+}
+
+// legacyFlags returns the flags of the former command line interface in the order
+// of their precedence, since only the first flag given is executed.
+func legacyFlags() []*legacyFlag {
+	return []*legacyFlag{
+		{name: "version", command: "version"},
+		{name: "instances", command: "instances"},
+		{name: "print-config", command: "config", args: []string{"print"}},
+		{name: "check-config", command: "config", args: []string{"check"}},
+		{name: "config", command: "config", hasValue: true},
+		{name: "pause", command: "pause"},
+		{name: "stop", command: "stop"},
+		{name: "next", command: "next"},
+		{name: "rew", command: "rew"},
+		{name: "mute", command: "mute"},
+		{name: "turn-up", command: "turn-up"},
+		{name: "turn-down", command: "turn-down"},
+		{name: "vol", command: "vol", hasValue: true},
+		{name: "info", command: "info"},
+		{name: "status", command: "status"},
+		{name: "list", command: "list"},
+		{name: "jump", command: "jump", hasValue: true},
+		{name: "remove", command: "remove", hasValue: true},
+		{name: "tui", command: "tui"},
+		{name: "completion", command: "completion", hasValue: true},
+		{name: "save", command: "save"},
+		{name: "cover", command: "cover"},
+		{name: "lyrics", command: "lyrics"},
+		{name: "play", command: "play", hasValue: true},
+		{name: "queue", command: "queue", hasValue: true},
+		{name: "path", command: "save", hasValue: true, option: true},
+		{name: "foreground", command: "play", option: true},
+		{name: "follow", command: "lyrics", option: true},
+		{name: "smart", command: "save", hasValue: true, option: true},
+	}
+}
+
+// defineLegacyFlags defines the former flags in the flag set, hidden from its usage.
+func defineLegacyFlags(fs *flag.FlagSet) []*legacyFlag {
+	flags := legacyFlags()
+	for _, f := range flags {
+		fs.Var(f, f.name, "")
+	}
+
+	return flags
+}
+
+// translateLegacy returns the command and the arguments equivalent to the former
+// flags that are set. The remaining arguments are appended, so that e.g.
+// -queue *.flac queues all matching files. ok is false if no such flag is set.
+func translateLegacy(cmds []*command, flags []*legacyFlag, rest []string) (name string, args []string, ok bool) {
+	var cmdFlag *legacyFlag
+	for _, f := range flags {
+		if f.set && !f.option {
+			cmdFlag = f
+			break
+		}
+	}
+	if cmdFlag == nil {
+		return "", nil, false
+	}
+
+	// Options such as -path are passed on only if the command understands them.
+	cmd, found := findCommand(cmds, cmdFlag.command)
+	for _, f := range flags {
+		if f.set && f.option && found && cmd.flags.Lookup(f.name) != nil {
+			if f.hasValue {
+				args = append(args, "-"+f.name+"="+f.value)
+			} else {
+				args = append(args, "-"+f.name)
+			}
+		}
+	}
+
+	args = append(args, cmdFlag.args...)
+	if cmdFlag.hasValue {
+		args = append(args, cmdFlag.value)
+	}
+
+	return cmdFlag.command, append(args, rest...), true
+}
+
+// printUsage prints the list of commands and the global flags of the flag set.
+func printUsage(w io.Writer, fs *flag.FlagSet, cmds []*command) {
+	fmt.Fprint(w, "Usage: scythix [global flags] COMMAND [ARGS...]\n\nCommands:\n")
+	for _, cmd := range cmds {
+		synopsis := cmd.name
+		if cmd.args != "" {
+			synopsis += " " + cmd.args
+		}
+		fmt.Fprintf(w, "  %-24s %s\n", synopsis, firstLine(cmd.summary))
+	}
+
+	global := flag.NewFlagSet("scythix", flag.ContinueOnError)
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, legacy := f.Value.(*legacyFlag); !legacy {
+			global.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	fmt.Fprintln(w, "\nGlobal flags:")
+	global.SetOutput(w)
+	global.PrintDefaults()
+
+	fmt.Fprint(w, "\nRun 'scythix help COMMAND' for the flags of a command.\n"+
+		"The flags of earlier versions, e.g. -play PATH or -next, are still accepted.\n")
+}
+
+// firstLine returns the first line of the text.
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
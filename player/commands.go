@@ -0,0 +1,651 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"scythix/client"
+	"scythix/conf"
+	"scythix/playlist"
+	"scythix/protocol"
+	"scythix/tui"
+)
+
+// tuiHelp describes the key bindings of the terminal interface.
+const tuiHelp = `Keys:
+  space      Pause or resume playback
+  n, p       Next and previous track
+  ←, →       Seek backward and forward by 5 seconds
+  +, -       Increase and decrease volume
+  m          Mute or unmute sound
+  ↑, ↓       Select a track in the queue (also k, j, PgUp, PgDn, g, G)
+  enter      Play the selected track
+  K, J       Move the selected track up and down
+  d, delete  Remove the selected track
+  q          Quit`
+
+// newCommands returns the commands of the command line interface in the order
+// they are listed in the usage. fs holds the global flags.
+func newCommands(fs *flag.FlagSet) []*command {
+	var cmds []*command
+
+	play := newCommand("play", "[PATH...]",
+		"Start playing the specified audio files, directories or playlists.\n"+
+			"Directories are searched recursively for supported audio files.", 0, -1)
+	foreground := play.flags.Bool("foreground", false, "Run the player in the foreground instead of detaching it, e.g. under a process supervisor")
+	playQuery := play.flags.String("query", "", "Play the tracks of the library matching the `QUERY`, see 'scythix help search'")
+	playAlbum := play.flags.String("album", "", "Play the album of the library with the `TITLE` in track order")
+	playArtist := play.flags.String("artist", "", "Choose the album given with -album among the albums of several artists by the `ARTIST`")
+	playSmart := play.flags.String("smart", "", "Play the smart playlist with the `NAME` defined in the config or the playlist directory")
+	playStdin := play.flags.Bool("stdin", false, "Also play the paths read from standard input, separated by NUL bytes as printed by find -print0")
+	play.untimed = true
+	play.complete = completeFiles
+	play.run = func(ctx context.Context, args []string) error {
+		if *playStdin {
+			paths, err := readPaths(os.Stdin)
+			if err != nil {
+				return err
+			}
+			args = append(args, paths...)
+		}
+		return runPlay(args, libraryArgs{query: *playQuery, album: *playAlbum, artist: *playArtist, smart: *playSmart}, *foreground)
+	}
+
+	queue := newCommand("queue", "[PATH...]",
+		"Add the specified audio files, directories or playlists to the playback queue.", 0, -1)
+	queueQuery := queue.flags.String("query", "", "Queue the tracks of the library matching the `QUERY`, see 'scythix help search'")
+	queueAlbum := queue.flags.String("album", "", "Queue the album of the library with the `TITLE` in track order")
+	queueArtist := queue.flags.String("artist", "", "Choose the album given with -album among the albums of several artists by the `ARTIST`")
+	queueSmart := queue.flags.String("smart", "", "Queue the smart playlist with the `NAME` defined in the config or the playlist directory")
+	queue.untimed = true
+	queue.complete = completeFiles
+	queue.run = func(ctx context.Context, args []string) error {
+		paths, err := resolvePaths(args, libraryArgs{query: *queueQuery, album: *queueAlbum, artist: *queueArtist, smart: *queueSmart})
+		if err != nil {
+			return err
+		}
+		return runQueue(ctx, paths)
+	}
+
+	pause := newCommand("pause", "", "Pause or resume playback.", 0, 0)
+	pause.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error { return c.Pause(ctx) })
+	}
+
+	stop := newCommand("stop", "", "Stop playback and shut down the player.", 0, 0)
+	stop.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error {
+			if err := c.Stop(ctx); err != nil {
+				return err
+			}
+			fmt.Println("See you.")
+			return nil
+		})
+	}
+
+	next := newCommand("next", "", "Skip to the next track.", 0, 0)
+	next.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error { return c.Next(ctx) })
+	}
+
+	rew := newCommand("rew", "", "Rewind to the previous track.", 0, 0)
+	rew.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error { return c.Rewind(ctx) })
+	}
+
+	mute := newCommand("mute", "", "Mute or unmute sound.", 0, 0)
+	mute.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error { return c.Mute(ctx) })
+	}
+
+	turnUp := newCommand("turn-up", "", "Increase volume.", 0, 0)
+	turnUp.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error {
+			return printVolume(c.TurnUp(ctx))
+		})
+	}
+
+	turnDown := newCommand("turn-down", "", "Decrease volume.", 0, 0)
+	turnDown.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error {
+			return printVolume(c.TurnDown(ctx))
+		})
+	}
+
+	vol := newCommand("vol", "LEVEL", "Set volume level (0–24).", 1, 1)
+	vol.run = func(ctx context.Context, args []string) error {
+		level, err := strconv.Atoi(args[0])
+		if err != nil || level < 0 {
+			return fmt.Errorf("%w: invalid volume level %q", ErrUsage, args[0])
+		}
+		return withClient(ctx, func(c *client.Client) error {
+			volLvl, err := c.SetVolume(ctx, level)
+			if err != nil {
+				return err
+			}
+			if float64(level) != volLvl {
+				fmt.Printf("vol: %g\n", volLvl)
+			}
+			return nil
+		})
+	}
+
+	info := newCommand("info", "", "Display track info.", 0, 0)
+	info.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error {
+			if jsonOutput {
+				return printTrackJSON(ctx, c)
+			}
+			st, err := c.Status(ctx)
+			if err != nil {
+				return err
+			}
+			return displayStatus(st, "info_format")
+		})
+	}
+
+	status := newCommand("status", "", "Display player status: playback state, position and volume.", 0, 0)
+	status.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error {
+			st, err := c.Status(ctx)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(newStatusJSON(st))
+			}
+			return displayStatus(st, "status_format")
+		})
+	}
+
+	list := newCommand("list", "", "Display current playlist.", 0, 0)
+	list.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error {
+			if !c.HasFeature(protocol.FeatureTracks) {
+				if jsonOutput || formatFlag != "" {
+					return ErrUnsupported
+				}
+				playlist, err := c.PlaylistInfo(ctx)
+				if err != nil {
+					return err
+				}
+				fmt.Println(playlist)
+				return nil
+			}
+
+			reply, err := c.Tracks(ctx)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(newQueueJSON(reply))
+			}
+			return displayTracks(reply)
+		})
+	}
+
+	jump := newCommand("jump", "INDEX", "Play the track at the given position of the queue.", 1, 1)
+	jump.complete = completeIndexes
+	jump.run = func(ctx context.Context, args []string) error {
+		index, err := parseIndex(args[0])
+		if err != nil {
+			return err
+		}
+		return withFeature(ctx, protocol.FeatureQueueEdit, func(c *client.Client) error {
+			return c.Jump(ctx, index)
+		})
+	}
+
+	remove := newCommand("remove", "INDEX...", "Remove the tracks at the given positions from the queue.", 1, -1)
+	remove.complete = completeIndexes
+	remove.run = func(ctx context.Context, args []string) error {
+		indexes := make([]int, 0, len(args))
+		for _, arg := range args {
+			index, err := parseIndex(arg)
+			if err != nil {
+				return err
+			}
+			indexes = append(indexes, index)
+		}
+		// Tracks are removed from the end, so that the positions of the others don't change.
+		slices.Sort(indexes)
+		indexes = slices.Compact(indexes)
+		return withFeature(ctx, protocol.FeatureQueueEdit, func(c *client.Client) error {
+			for _, index := range slices.Backward(indexes) {
+				if err := c.Remove(ctx, index); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	move := newCommand("move", "FROM TO", "Move the track at one position of the queue to another.", 2, 2)
+	move.complete = completeIndexes
+	move.run = func(ctx context.Context, args []string) error {
+		from, err := parseIndex(args[0])
+		if err != nil {
+			return err
+		}
+		to, err := parseIndex(args[1])
+		if err != nil {
+			return err
+		}
+		return withFeature(ctx, protocol.FeatureQueueEdit, func(c *client.Client) error {
+			return c.Move(ctx, from, to)
+		})
+	}
+
+	seek := newCommand("seek", "POSITION",
+		"Change the playback position of the current track.\n"+
+			"The position is given in seconds or as MM:SS, e.g. 90 or 1:30.\n"+
+			"A leading + or - moves relative to the current position, e.g. +10 or -0:30.", 1, 1)
+	seek.run = func(ctx context.Context, args []string) error {
+		pos, relative, err := parsePosition(args[0])
+		if err != nil {
+			return err
+		}
+		return withFeature(ctx, protocol.FeatureSeek, func(c *client.Client) error {
+			return c.Seek(ctx, pos, relative)
+		})
+	}
+
+	ui := newCommand("tui", "", "Open the interactive terminal interface.\n\n"+tuiHelp, 0, 0)
+	ui.untimed = true
+	ui.run = func(ctx context.Context, args []string) error {
+		return withClient(ctx, func(c *client.Client) error {
+			name := instanceName
+			if name == "" {
+				name = defaultInstance
+			}
+			return tui.Run(ctx, c, name)
+		})
+	}
+
+	save := newCommand("save", "", "Save current playlist, or a snapshot of a smart playlist.", 0, 0)
+	playlistDir := save.flags.String("path", "-", "Specify path for saving playlist. By default, path specified in the config is used")
+	saveSmartFlag := save.flags.String("smart", "", "Save the tracks the smart playlist with the `NAME` or in the .smart file currently selects")
+	save.run = func(ctx context.Context, args []string) error {
+		if *saveSmartFlag != "" {
+			playlistPath, err := saveSmart(*saveSmartFlag, *playlistDir)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Playlist saved %s\n", playlistPath)
+			return nil
+		}
+		return withClient(ctx, func(c *client.Client) error {
+			playlistPath, err := c.SavePlaylist(ctx, *playlistDir)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Playlist saved %s\n", playlistPath)
+			return nil
+		})
+	}
+
+	cover := newCommand("cover", "[FILE]",
+		"Export the cover art of the current track: the picture embedded in the audio file,\n"+
+			"or cover.jpg, folder.png or a similar image in its directory.\n"+
+			"It is written to FILE, to cover.jpg or cover.png by default unless the file exists,\n"+
+			"or to the standard output if FILE is -.", 0, 1)
+	cover.complete = completeAnyFiles
+	cover.run = func(ctx context.Context, args []string) error {
+		return withFeature(ctx, protocol.FeatureCover, func(c *client.Client) error {
+			reply, err := c.Cover(ctx)
+			if err != nil {
+				return err
+			}
+			return saveCover(reply, args)
+		})
+	}
+
+	lyricsCmd := newCommand("lyrics", "",
+		"Display the lyrics of the current track, read from an .lrc file with the same name\n"+
+			"as the audio file or from its tags.", 0, 0)
+	follow := lyricsCmd.flags.Bool("follow", false, "Print the line being sung whenever it changes, until the player stops")
+	lyricsCmd.untimed = true
+	lyricsCmd.run = func(ctx context.Context, args []string) error {
+		return withFeature(ctx, protocol.FeatureLyrics, func(c *client.Client) error {
+			if *follow {
+				return followLyrics(ctx, c)
+			}
+			return printLyrics(ctx, c)
+		})
+	}
+
+	tag := newCommand("tag", "show|set [FLAGS] FILE...", tagUsage, 2, -1)
+	tag.untimed = true
+	tag.complete = completeTag
+	tag.run = runTag
+
+	lib := newCommand("library", "scan [DIR...] | info", libraryUsage, 1, -1)
+	lib.untimed = true
+	lib.complete = completeLibrary
+	lib.run = func(ctx context.Context, args []string) error {
+		return runLibrary(args)
+	}
+
+	search := newCommand("search", "QUERY...", searchUsage, 1, -1)
+	search.run = func(ctx context.Context, args []string) error {
+		return runSearch(args)
+	}
+
+	ls := newCommand("ls", "artists | albums [-artist ARTIST] | genres", lsUsage, 1, 3)
+	ls.complete = completeLs
+	ls.run = func(ctx context.Context, args []string) error {
+		return runLs(args)
+	}
+
+	version := newCommand("version", "", "Display version information of the player and the running daemon.", 0, 0)
+	version.run = func(ctx context.Context, args []string) error {
+		return displayVersion(ctx)
+	}
+
+	instances := newCommand("instances", "", "List running player instances.", 0, 0)
+	instances.run = func(ctx context.Context, args []string) error {
+		return displayInstances(ctx)
+	}
+
+	config := newCommand("config", "get|set|print|check [ARGS...]",
+		"Manage configuration.\n\n"+
+			"  get KEY        Display the value of a config key\n"+
+			"  set KEY VALUE  Change a config key, the running player applies it immediately\n"+
+			"  print          Display the effective configuration and where each value comes from\n"+
+			"  check          Check the config file for problems", 1, 3)
+	config.complete = completeConfig
+	config.run = runConfig
+
+	shellCompletion := newCommand("completion", "SHELL",
+		"Print the shell completion script for bash, zsh or fish.\n\n"+
+			"  bash  source <(scythix completion bash)\n"+
+			"  zsh   source <(scythix completion zsh)\n"+
+			"  fish  scythix completion fish | source", 1, 1)
+	shellCompletion.complete = completeShells
+	shellCompletion.run = func(ctx context.Context, args []string) error {
+		return printCompletion(args[0])
+	}
+
+	help := newCommand("help", "[COMMAND]", "Display help for a command.", 0, 1)
+	help.complete = completeCommands
+	help.run = func(ctx context.Context, args []string) error {
+		if len(args) == 0 {
+			printUsage(os.Stdout, fs, cmds)
+			return nil
+		}
+		cmd, ok := findCommand(cmds, args[0])
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownCommand, args[0])
+		}
+		cmd.usage(os.Stdout)
+		return nil
+	}
+
+	cmds = []*command{
+		play, queue, pause, stop, next, rew, mute, turnUp, turnDown, vol,
+		info, status, list, jump, remove, move, seek, ui, save, cover, lyricsCmd, tag, lib, search, ls,
+		version, instances, config, shellCompletion, help,
+	}
+
+	return cmds
+}
+
+// withClient connects to the player server and calls fn with the client.
+func withClient(ctx context.Context, fn func(c *client.Client) error) error {
+	c, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return fn(c)
+}
+
+// withFeature connects to the player server and calls fn with the client,
+// if the server supports the feature.
+func withFeature(ctx context.Context, feature string, fn func(c *client.Client) error) error {
+	return withClient(ctx, func(c *client.Client) error {
+		if !c.HasFeature(feature) {
+			return ErrUnsupported
+		}
+		return fn(c)
+	})
+}
+
+// parseIndex parses a position in the queue, starting at 1.
+func parseIndex(arg string) (int, error) {
+	index, err := strconv.Atoi(arg)
+	if err != nil || index < 1 {
+		return 0, fmt.Errorf("%w: invalid track index %q", ErrUsage, arg)
+	}
+
+	return index, nil
+}
+
+// parsePosition parses a playback position given in seconds or as MM:SS.
+// A leading sign makes the position relative to the current one.
+func parsePosition(arg string) (time.Duration, bool, error) {
+	invalid := fmt.Errorf("%w: invalid position %q", ErrUsage, arg)
+
+	s, sign := arg, time.Duration(1)
+	relative := strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-")
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+	}
+	if relative {
+		s = s[1:]
+	}
+
+	min, sec, found := strings.Cut(s, ":")
+	if !found {
+		min, sec = "0", s
+	}
+	m, err := strconv.Atoi(min)
+	if err != nil || m < 0 {
+		return 0, false, invalid
+	}
+	secs, err := strconv.ParseFloat(sec, 64)
+	if err != nil || secs < 0 || (found && secs >= 60) {
+		return 0, false, invalid
+	}
+
+	pos := time.Duration(m)*time.Minute + time.Duration(secs*float64(time.Second))
+	return sign * pos, relative, nil
+}
+
+// printVolume prints the volume level returned by a volume control call.
+func printVolume(volLvl float64, err error) error {
+	if err != nil {
+		return err
+	}
+	fmt.Printf("vol: %g\n", volLvl)
+
+	return nil
+}
+
+// printTrackJSON prints the current track as a JSON document, or null if nothing is playing.
+func printTrackJSON(ctx context.Context, c *client.Client) error {
+	if !c.HasFeature(protocol.FeatureStatus) {
+		return ErrUnsupported
+	}
+	st, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if !st.Playing {
+		return printJSON(nil)
+	}
+
+	return printJSON(newTrackJSON(protocol.Track{
+		Index:    st.Index,
+		Current:  true,
+		Path:     st.Path,
+		Prop:     st.Track,
+		Duration: st.Duration,
+	}))
+}
+
+// saveCover writes the cover art to the file given in args, or to a file named after
+// its type in the current directory.
+func saveCover(reply *protocol.CoverReply, args []string) error {
+	out := "cover" + (&playlist.Cover{MIMEType: reply.MIMEType}).Ext()
+	if len(args) > 0 {
+		out = args[0]
+	}
+	if out == "-" {
+		_, err := os.Stdout.Write(reply.Data)
+		return err
+	}
+
+	// The default file is not overwritten, a FILE given explicitly is.
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if len(args) == 0 {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	}
+	f, err := os.OpenFile(out, mode, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, give the FILE to write to", out)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(reply.Data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(coverJSON{Path: out, MIMEType: reply.MIMEType, Size: len(reply.Data), Source: reply.Source})
+	}
+	fmt.Printf("Cover saved %s\n", out)
+
+	return nil
+}
+
+// libraryArgs holds the flags of play and queue selecting tracks of the library.
+type libraryArgs struct {
+	query  string
+	album  string
+	artist string
+	smart  string
+}
+
+// resolvePaths returns the paths to queue for the arguments of play and queue:
+// the expanded paths given, followed by the tracks of the library matching the
+// query, the tracks of the album and the tracks of the smart playlist.
+func resolvePaths(args []string, lib libraryArgs) ([]string, error) {
+	if lib.artist != "" && lib.album == "" {
+		return nil, fmt.Errorf("%w: -artist requires -album", ErrUsage)
+	}
+	if len(args) == 0 && lib == (libraryArgs{}) {
+		return nil, fmt.Errorf("%w: no paths, query, album or smart playlist given", ErrUsage)
+	}
+
+	paths, err := expandPaths(args)
+	if err != nil {
+		return nil, err
+	}
+	if lib.query != "" {
+		found, err := queryPaths(lib.query)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, found...)
+	}
+	if lib.album != "" {
+		found, err := albumPaths(lib.album, lib.artist)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, found...)
+	}
+	if lib.smart != "" {
+		found, err := smartPaths(lib.smart)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, found...)
+	}
+
+	return paths, nil
+}
+
+// readPaths returns the NUL-separated paths read from r.
+func readPaths(r io.Reader) ([]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.FieldsFunc(string(b), func(c rune) bool { return c == 0 }), nil
+}
+
+// runPlay starts a player with the given audio files, playlists and tracks of the library.
+func runPlay(args []string, lib libraryArgs, foreground bool) error {
+	if pid := daemonPID(lockFile); pid != 0 {
+		return fmt.Errorf("%w [PID:%d], use 'scythix queue' to add tracks", ErrAlreadyRunning, pid)
+	}
+
+	// The paths are resolved before the daemon is started, so that errors are
+	// reported right away instead of in the log.
+	paths, err := resolvePaths(args, lib)
+	if err != nil {
+		return err
+	}
+	if foreground {
+		return RunDaemon(paths)
+	}
+	if _, _, err := loadConfig(); err != nil {
+		return err
+	}
+
+	return startDaemon(paths)
+}
+
+// runQueue adds the given audio files and playlists to the playback queue.
+// Every file is queued with a separate call, and the files that can't be queued
+// are reported together.
+func runQueue(ctx context.Context, paths []string) error {
+	c, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var errs []error
+	for _, p := range paths {
+		callCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		if err := c.Queue(callCtx, p); err != nil {
+			errs = append(errs, fmt.Errorf("unable to queue %s: %w", p, err))
+		}
+		cancel()
+	}
+
+	return errors.Join(errs...)
+}
+
+// runConfig runs a subcommand of the config command.
+func runConfig(ctx context.Context, args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "print":
+		return printConfig()
+	case len(args) == 1 && args[0] == "check":
+		return checkConfig()
+	case len(args) == 2 && args[0] == "get", len(args) == 3 && args[0] == "set":
+		return runConfigCommand(ctx, args[0], args[1:])
+	}
+
+	return fmt.Errorf("%w: expected get KEY, set KEY VALUE, print or check (keys: %s)",
+		ErrUsage, strings.Join(conf.Keys(), ", "))
+}
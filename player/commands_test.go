@@ -0,0 +1,44 @@
+package player
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		arg      string
+		want     time.Duration
+		relative bool
+		wantErr  error
+	}{
+		{arg: "90", want: 90 * time.Second},
+		{arg: "1:30", want: 90 * time.Second},
+		{arg: "0:05.5", want: 5500 * time.Millisecond},
+		{arg: "12.25", want: 12250 * time.Millisecond},
+		{arg: "+10", want: 10 * time.Second, relative: true},
+		{arg: "-1:00", want: -time.Minute, relative: true},
+		{arg: "0", want: 0},
+		{arg: "1:60", wantErr: ErrUsage},
+		{arg: "-1:-5", wantErr: ErrUsage},
+		{arg: "--5", wantErr: ErrUsage},
+		{arg: "abc", wantErr: ErrUsage},
+		{arg: "", wantErr: ErrUsage},
+		{arg: "1:", wantErr: ErrUsage},
+	}
+
+	for _, tt := range tests {
+		got, relative, err := parsePosition(tt.arg)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("parsePosition(%q) error = %v, want %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got != tt.want || relative != tt.relative {
+			t.Errorf("parsePosition(%q) = %v, %v, want %v, %v", tt.arg, got, relative, tt.want, tt.relative)
+		}
+	}
+}
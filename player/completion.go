@@ -0,0 +1,512 @@
+package player
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"scythix/client"
+	"scythix/conf"
+	"scythix/env"
+	"scythix/playlist"
+	"scythix/protocol"
+	"scythix/tagedit"
+	"scythix/trackfmt"
+)
+
+// completeCommand is the hidden command the completion scripts call to complete
+// a word of the command line. It takes the words following the program name up to
+// and including the one being completed.
+//
+// The output starts with a directive telling the script which files to offer:
+// ":files EXT,..." for the files with the given extensions, ":anyfiles", ":dirs",
+// ":nospace" if no space may follow the word, or ":words" for the candidates only.
+// Every following line holds a candidate and its description separated by a tab.
+const completeCommand = "__complete"
+
+// Kinds of the positional arguments of commands.
+const (
+	completeFiles    = "files"
+	completeIndexes  = "indexes"
+	completeCommands = "commands"
+	completeConfig   = "config"
+	completeShells   = "shells"
+	completeTag      = "tag"
+	completeLibrary  = "library"
+	completeLs       = "ls"
+)
+
+// Kinds of the values of global flags, which may also be positional arguments.
+const (
+	completeAnyFiles    = "anyfiles"
+	completeInstances   = "instances"
+	completeAssignments = "assignments"
+)
+
+// flagValueCompletion maps the global flags to the kinds of their values.
+var flagValueCompletion = map[string]string{
+	"socket":      completeAnyFiles,
+	"config-file": completeAnyFiles,
+	"instance":    completeInstances,
+	"set":         completeAssignments,
+}
+
+// playlistExts lists the extensions of the playlist files that can be queued.
+var playlistExts = []string{"m3u", "m3u8", "smart"}
+
+// completionShells lists the shells completion scripts are generated for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// candidate is a completion of a word.
+type candidate struct {
+	value       string
+	description string
+}
+
+// completion holds the result of completing a word.
+type completion struct {
+	directive  string
+	candidates []candidate
+}
+
+// add appends a candidate.
+func (c *completion) add(value, description string) {
+	c.candidates = append(c.candidates, candidate{value, description})
+}
+
+// runComplete prints the completions of the last word of the command line.
+// The global flags found in the words are parsed, and setup is called to
+// resolve the player instance before the daemon is asked for dynamic values.
+func runComplete(fs *flag.FlagSet, cmds []*command, legacy []*legacyFlag, words []string, setup func() error) int {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, prior := words[len(words)-1], words[:len(words)-1]
+
+	// A flag waiting for its value is set aside, since it can't be parsed.
+	var valueFlag string
+	if len(prior) > 0 && needsValue(fs, prior[len(prior)-1]) {
+		valueFlag = flagName(prior[len(prior)-1])
+		prior = prior[:len(prior)-1]
+	}
+
+	fs.SetOutput(io.Discard)
+	var c completion
+	if err := fs.Parse(prior); err == nil {
+		// Without the daemon and the config, only the static candidates are offered.
+		setupErr := setup()
+		c = completeWord(fs, cmds, legacy, valueFlag, cur, setupErr == nil)
+	}
+	if c.directive == "" {
+		c.directive = ":words"
+	}
+
+	fmt.Println(c.directive)
+	for _, cand := range c.candidates {
+		if strings.HasPrefix(cand.value, cur) || c.directive == ":nospace" {
+			fmt.Printf("%s\t%s\n", cand.value, cand.description)
+		}
+	}
+
+	return exitOK
+}
+
+// completeWord returns the completions of the word cur, which follows the parsed
+// flags and arguments, or the value of valueFlag if set.
+func completeWord(fs *flag.FlagSet, cmds []*command, legacy []*legacyFlag, valueFlag, cur string, dynamic bool) completion {
+	var c completion
+
+	// The value of a flag of the former interface is the first argument of its command.
+	var cmd *command
+	var args []string
+	for _, f := range legacy {
+		if f.name == valueFlag && !f.option {
+			cmd, _ = findCommand(cmds, f.command)
+			args = f.args
+			valueFlag = ""
+		}
+	}
+
+	switch {
+	case valueFlag == "path":
+		c.directive = ":dirs"
+		return c
+	case valueFlag != "":
+		completeFlagValue(&c, flagValueCompletion[valueFlag], cur, dynamic)
+		return c
+	case cmd != nil:
+	default:
+		name, rest, ok := translateLegacy(cmds, legacy, fs.Args())
+		if !ok && fs.NArg() > 0 {
+			name, rest, ok = fs.Arg(0), fs.Args()[1:], true
+		}
+		if ok {
+			if cmd, ok = findCommand(cmds, name); !ok {
+				return c
+			}
+			args = rest
+		}
+	}
+
+	if cmd == nil {
+		if strings.HasPrefix(cur, "-") {
+			completeFlags(&c, fs, cmds)
+			return c
+		}
+		for _, cmd := range cmds {
+			c.add(cmd.name, firstLine(cmd.summary))
+		}
+		return c
+	}
+
+	// Flags of the command taking a value, such as save -path.
+	if len(args) > 0 && needsValue(cmd.flags, args[len(args)-1]) {
+		c.directive = ":dirs"
+		return c
+	}
+	if strings.HasPrefix(cur, "-") {
+		if cmd.complete == completeTag && len(args) > 0 && args[0] == "set" {
+			for _, field := range tagedit.Fields() {
+				c.add("--"+field, "")
+			}
+			return c
+		}
+		completeFlags(&c, cmd.flags, nil)
+		return c
+	}
+
+	cmd.flags.SetOutput(io.Discard)
+	if hasFlags(cmd.flags) && cmd.flags.Parse(args) == nil {
+		args = cmd.flags.Args()
+	}
+	completeArgs(&c, cmd, cmds, args, dynamic)
+
+	return c
+}
+
+// completeFlags adds the flags defined in the flag set. The former flags are
+// described by the summaries of their commands.
+func completeFlags(c *completion, fs *flag.FlagSet, cmds []*command) {
+	fs.VisitAll(func(f *flag.Flag) {
+		usage := f.Usage
+		if lf, ok := f.Value.(*legacyFlag); ok {
+			if cmd, found := findCommand(cmds, lf.command); found {
+				usage = firstLine(cmd.summary)
+			}
+		}
+		c.add("-"+f.Name, firstLine(usage))
+	})
+}
+
+// completeFlagValue adds the values of a global flag of the given kind.
+func completeFlagValue(c *completion, kind, cur string, dynamic bool) {
+	switch kind {
+	case completeAnyFiles:
+		c.directive = ":anyfiles"
+	case completeInstances:
+		if !dynamic {
+			return
+		}
+		instances, _ := listInstances()
+		for _, inst := range instances {
+			if pid, err := lockOwner(inst.lock); err == nil && pid != 0 {
+				c.add(inst.name, fmt.Sprintf("PID %d", pid))
+			}
+		}
+	case completeAssignments:
+		c.directive = ":nospace"
+		for _, key := range conf.Keys() {
+			if strings.HasPrefix(key+"=", cur) {
+				c.add(key+"=", "")
+			}
+		}
+	}
+}
+
+// completeArgs adds the candidates for the next positional argument of the command.
+func completeArgs(c *completion, cmd *command, cmds []*command, args []string, dynamic bool) {
+	switch cmd.complete {
+	case completeFiles:
+		c.directive = ":files " + strings.Join(append(playlist.SupportedFormats(), playlistExts...), ",")
+		if dynamic {
+			for _, p := range savedPlaylists() {
+				c.add(p, "playlist")
+			}
+		}
+	case completeIndexes:
+		if dynamic {
+			addQueueIndexes(c)
+		}
+	case completeCommands:
+		if len(args) == 0 {
+			for _, cmd := range cmds {
+				c.add(cmd.name, firstLine(cmd.summary))
+			}
+		}
+	case completeConfig:
+		switch {
+		case len(args) == 0:
+			c.add("get", "Display the value of a config key")
+			c.add("set", "Change a config key")
+			c.add("print", "Display the effective configuration")
+			c.add("check", "Check the config file for problems")
+		case len(args) == 1 && (args[0] == "get" || args[0] == "set"):
+			for _, key := range conf.Keys() {
+				c.add(key, "")
+			}
+		}
+	case completeAnyFiles:
+		c.directive = ":anyfiles"
+	case completeTag:
+		if len(args) == 0 {
+			c.add("show", "Display the tags")
+			c.add("set", "Change the tags")
+			return
+		}
+		// The value of a flag of set is left to the user.
+		if args[0] == "set" && len(args) > 1 && strings.HasPrefix(args[len(args)-1], "-") &&
+			!strings.Contains(args[len(args)-1], "=") {
+			return
+		}
+		c.directive = ":files " + strings.Join(playlist.SupportedFormats(), ",")
+	case completeLibrary:
+		switch {
+		case len(args) == 0:
+			c.add("scan", "Index the audio files of directories")
+			c.add("info", "Display the scanned directories")
+		case args[0] == "scan":
+			c.directive = ":dirs"
+		}
+	case completeLs:
+		if len(args) == 0 {
+			c.add("artists", "List the artists")
+			c.add("albums", "List the albums")
+			c.add("genres", "List the genres")
+		}
+	case completeShells:
+		if len(args) == 0 {
+			for _, shell := range completionShells {
+				c.add(shell, "")
+			}
+		}
+	}
+}
+
+// addQueueIndexes adds the positions of the tracks queued in the running player.
+func addQueueIndexes(c *completion) {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	// The lock is checked without connect, which logs problems with it.
+	if pid, err := lockOwner(lockFile); err != nil || pid == 0 {
+		return
+	}
+	cl, err := client.Dial(ctx, socketPath)
+	if err != nil {
+		return
+	}
+	defer cl.Close()
+	if !cl.HasFeature(protocol.FeatureTracks) {
+		return
+	}
+	reply, err := cl.Tracks(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, t := range reply.Tracks {
+		fields := trackfmt.TrackFields(t, 0)
+		c.add(fields["index"], trackfmt.Name(fields))
+	}
+}
+
+// savedPlaylists returns the playlist files in the configured playlist directory.
+func savedPlaylists() []string {
+	cfg, _, err := effectiveConfig()
+	if err != nil {
+		return nil
+	}
+	dir, err := env.ExpandPath(cfg.PlaylistDir)
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var playlists []string
+	for _, e := range entries {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(e.Name()), "."))
+		if !e.IsDir() && slices.Contains(playlistExts, ext) {
+			playlists = append(playlists, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return playlists
+}
+
+// needsValue reports whether the argument is a flag of the flag set that takes
+// its value from the next argument.
+func needsValue(fs *flag.FlagSet, arg string) bool {
+	if !strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+		return false
+	}
+	f := fs.Lookup(flagName(arg))
+	if f == nil {
+		return false
+	}
+	if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+		return false
+	}
+
+	return true
+}
+
+// flagName returns the name of the flag given as an argument, e.g. "play" for "--play".
+func flagName(arg string) string {
+	return strings.TrimLeft(arg, "-")
+}
+
+// printCompletion prints the completion script for the shell.
+func printCompletion(shell string) error {
+	scripts := map[string]string{
+		"bash": bashCompletion,
+		"zsh":  zshCompletion,
+		"fish": fishCompletion,
+	}
+	script, ok := scripts[shell]
+	if !ok {
+		return fmt.Errorf("%w: unsupported shell %q, expected one of %s",
+			ErrUsage, shell, strings.Join(completionShells, ", "))
+	}
+	_, err := io.WriteString(os.Stdout, script)
+
+	return err
+}
+
+const bashCompletion = `# bash completion for scythix
+# Load it in the current shell with:
+#   source <(scythix completion bash)
+
+_scythix() {
+    local cur=${COMP_WORDS[COMP_CWORD]} directive line
+    local -a out
+    COMPREPLY=()
+
+    mapfile -t out < <(scythix __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)
+    directive=${out[0]}
+    for line in "${out[@]:1}"; do
+        COMPREPLY+=("${line%%$'\t'*}")
+    done
+
+    case $directive in
+        ":files "*)
+            local exts=${directive#:files }
+            compopt -o filenames
+            while IFS= read -r line; do
+                COMPREPLY+=("$line")
+            done < <(compgen -d -- "$cur"; compgen -f -- "$cur" | grep -iE "\.(${exts//,/|})\$")
+            ;;
+        :anyfiles)
+            compopt -o filenames
+            while IFS= read -r line; do
+                COMPREPLY+=("$line")
+            done < <(compgen -f -- "$cur")
+            ;;
+        :dirs)
+            compopt -o filenames
+            while IFS= read -r line; do
+                COMPREPLY+=("$line")
+            done < <(compgen -d -- "$cur")
+            ;;
+        :nospace)
+            compopt -o nospace
+            ;;
+    esac
+}
+
+complete -F _scythix scythix
+`
+
+const zshCompletion = `#compdef scythix
+# zsh completion for scythix
+# Load it in the current shell with:
+#   source <(scythix completion zsh)
+# or save it as _scythix in a directory of $fpath.
+
+_scythix() {
+    local -a out cands
+    local directive line
+
+    out=("${(@f)$(scythix __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    directive=$out[1]
+    for line in "${(@)out[2,-1]}"; do
+        [[ -n $line ]] || continue
+        cands+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+    done
+
+    case $directive in
+        ":files "*)
+            local exts=${directive#:files }
+            _files -g "*.(#i)(${exts//,/|})"
+            ;;
+        :anyfiles)
+            _files
+            ;;
+        :dirs)
+            _files -/
+            ;;
+    esac
+
+    if (( $#cands )); then
+        if [[ $directive == :nospace ]]; then
+            _describe -V values cands -S ''
+        else
+            _describe -V values cands
+        fi
+    fi
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    _scythix "$@"
+else
+    compdef _scythix scythix
+fi
+`
+
+const fishCompletion = `# fish completion for scythix
+# Load it in the current shell with:
+#   scythix completion fish | source
+
+function __scythix_complete
+    set -l tokens (commandline -opc)
+    set -l cur (commandline -ct)
+    set -l out (scythix __complete $tokens[2..-1] "$cur" 2>/dev/null)
+    set -l directive $out[1]
+    printf '%s\n' $out[2..-1]
+
+    switch "$directive"
+        case ':files *'
+            set -l exts (string split , -- (string replace ':files ' '' -- $directive))
+            for f in $cur*
+                if test -d $f
+                    echo $f/
+                else if contains -- (string lower -- (string match -r '[^.]*$' -- $f)) $exts
+                    echo $f
+                end
+            end
+        case :anyfiles
+            __fish_complete_path "$cur"
+        case :dirs
+            __fish_complete_directories "$cur"
+    end
+end
+
+complete -c scythix -f -a '(__scythix_complete)'
+`
@@ -0,0 +1,211 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"scythix/client"
+	"scythix/conf"
+	"scythix/env"
+	"scythix/protocol"
+	"scythix/trackfmt"
+)
+
+// runConfigCommand gets or sets a config key. If the player server is running,
+// the command is executed by the server, so that changes take effect immediately.
+// Otherwise the config file is accessed directly.
+func runConfigCommand(ctx context.Context, cmd string, args []string) error {
+	if daemonPID(lockFile) != 0 {
+		c, err := connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		if c.HasFeature(protocol.FeatureConfig) {
+			return configViaServer(ctx, c, cmd, args)
+		}
+	}
+
+	if cmd == "get" {
+		cfg, _, err := effectiveConfig()
+		if err != nil {
+			return err
+		}
+		value, err := cfg.Get(args[0])
+		if err != nil {
+			return err
+		}
+		return printConfigValue(args[0], value)
+	}
+
+	cfg, err := conf.Load(confFile)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set(args[0], args[1]); err != nil {
+		return err
+	}
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+	return conf.UpdateFile(confFile, cfg, args[0])
+}
+
+// printConfigValue prints the value of the config key.
+func printConfigValue(key, value string) error {
+	if jsonOutput {
+		return printJSON(map[string]string{"key": key, "value": value})
+	}
+	fmt.Println(value)
+
+	return nil
+}
+
+// confOverrides holds the config values set with command line flags.
+var confOverrides []conf.Override
+
+// addConfigOverride parses a KEY=VALUE argument of the -set flag.
+func addConfigOverride(arg string) error {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return fmt.Errorf("expected KEY=VALUE, got %q", arg)
+	}
+	if !slices.Contains(conf.Keys(), key) {
+		return fmt.Errorf("%w: %q", conf.ErrUnknownKey, key)
+	}
+	confOverrides = append(confOverrides, conf.Override{Key: key, Value: value, Flag: "set"})
+
+	return nil
+}
+
+// effectiveConfig returns the configuration merged from the defaults, the config
+// file, the environment and the command line, along with the sources of the values.
+func effectiveConfig() (*conf.Config, conf.Sources, error) {
+	return conf.LoadEffective(confFile, confOverrides)
+}
+
+// printConfig prints the effective configuration and where each value comes from.
+func printConfig() error {
+	cfg, sources, err := effectiveConfig()
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(struct {
+			Config  *conf.Config `json:"config"`
+			Sources conf.Sources `json:"sources"`
+		}{cfg, sources})
+	}
+
+	text, err := cfg.Format(sources)
+	if err != nil {
+		return err
+	}
+	fmt.Print(text)
+
+	return nil
+}
+
+// loadConfig returns the location of the config file and the effective configuration.
+// The file is created with default settings if it doesn't exist, and a file written by
+// an older version of the player is migrated to the current layout. An error is returned
+// if the file can't be parsed or the configuration contains invalid values.
+func loadConfig() (string, *conf.Config, error) {
+	confPath := confFile
+	if !env.PathExists(confPath) {
+		log.Debug("Create new config file")
+		if _, err := conf.CreateDefault(confPath); err != nil {
+			return "", nil, fmt.Errorf("unable to create default config file: %w", err)
+		}
+	} else if migrated, err := conf.Migrate(confPath); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", confPath, err)
+	} else if migrated {
+		log.Infof("Config file migrated to version %d", conf.CurrentVersion)
+	}
+
+	cfg, _, err := effectiveConfig()
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", confPath, err)
+	}
+	if err := validateConfig(cfg); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", confPath, err)
+	}
+	log.Debug("Read config file")
+
+	return confPath, cfg, nil
+}
+
+// formatKeys are the config keys holding trackfmt formats.
+var formatKeys = []string{"now_playing_template", "info_format", "list_format", "status_format"}
+
+// validateConfig checks the config values with conf.Config.Validate, as well as
+// its formats and the queries of its smart playlists.
+func validateConfig(cfg *conf.Config) error {
+	errs := configErrors(cfg)
+	err := cfg.Validate()
+	switch {
+	case len(errs) == 0:
+		return err
+	case err == nil:
+		return fmt.Errorf("%w:\n%w", conf.ErrInvalidConfig, errors.Join(errs...))
+	}
+
+	return fmt.Errorf("%w\n%w", err, errors.Join(errs...))
+}
+
+// configErrors returns the problems found in the formats and the smart playlists
+// of the config, which the conf package doesn't check.
+func configErrors(cfg *conf.Config) []error {
+	var errs []error
+	for _, key := range formatKeys {
+		text, _ := cfg.Get(key)
+		if _, err := trackfmt.Parse(text); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	return append(errs, smartPlaylistErrors(cfg)...)
+}
+
+// checkConfig reports the problems found in the config file and returns
+// an error if the file is not valid.
+func checkConfig() error {
+	confPath := confFile
+	problems, err := conf.Check(confPath)
+	if err != nil {
+		return err
+	}
+	if cfg, err := conf.Load(confPath); err == nil {
+		for _, err := range configErrors(cfg) {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s: OK\n", confPath)
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", confPath, problem)
+	}
+	return fmt.Errorf("%w: %d problem(s) found", conf.ErrInvalidConfig, len(problems))
+}
+
+// configViaServer gets or sets a config key of the running player server.
+func configViaServer(ctx context.Context, c *client.Client, cmd string, args []string) error {
+	if cmd == "get" {
+		value, err := c.ConfigGet(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return printConfigValue(args[0], value)
+	}
+
+	return c.ConfigSet(ctx, args[0], args[1])
+}
@@ -3,9 +3,12 @@ package player
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/rpc"
 	"os"
+	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,11 +17,11 @@ import (
 	"github.com/gopxl/beep/speaker"
 	log "github.com/sirupsen/logrus"
 
+	"scythix/client"
 	"scythix/conf"
+	"scythix/protocol"
 )
 
-const lockFile = "/tmp/scythix.lock"
-
 const (
 	defaultVol  float64 = -5
 	volStep     float64 = 0.5
@@ -26,54 +29,126 @@ const (
 	volLimitMin float64 = -12
 )
 
-// RunDaemon forks the current process to run in the background, initializes the player server
-// and manages playback of the specified target audio file or playlist.
-func RunDaemon(targetPath string) error {
-	// Check if the process is not a child (not forked).
-	if _, isChild := os.LookupEnv("FORKED"); !isChild {
-		// Fork and execute a new process with the same program arguments and
-		// an additional environment variable "FORKED=1".
-		exePath, err := os.Executable()
-		if err != nil {
-			log.Errorf("Failed to fork process: %v", err)
-			return fmt.Errorf("%w: %v", ErrFailedToFork, err)
+// daemonStartTimeout limits the time startDaemon waits for the daemon to accept connections.
+const daemonStartTimeout = 3 * time.Second
+
+// startDaemon starts the player in the background as a detached process.
+// The daemon runs in a new session with its working directory set to the root
+// and its output appended to the log. startDaemon waits until the daemon accepts
+// connections on the control socket. The paths to play are written to the standard
+// input of the daemon, since they may exceed the size limit of the arguments of
+// a process.
+func startDaemon(paths []string) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToFork, err)
+	}
+
+	stdin, pathsOut, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToFork, err)
+	}
+	defer stdin.Close()
+	defer pathsOut.Close()
+
+	out, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToFork, err)
+	}
+	defer out.Close()
+
+	args := []string{exePath, "-socket", socketPath, "-config-file", confFile}
+	if instanceName != "" {
+		args = append(args, "-instance", instanceName)
+	}
+	for _, o := range confOverrides {
+		if o.Flag == "set" {
+			args = append(args, "-set", o.Key+"="+o.Value)
 		}
-		pid, err := syscall.ForkExec(exePath, os.Args, &syscall.ProcAttr{
-			Env:   append(os.Environ(), "FORKED=1"),
-			Files: []uintptr{uintptr(syscall.Stderr), uintptr(syscall.Stdout), uintptr(syscall.Stdin)},
-		})
-		if err != nil {
-			log.Errorf("ForkExec failed: %v", err)
-			return fmt.Errorf("%w: %v", ErrFailedToFork, err)
+	}
+	args = append(args, "play", "-foreground", "-stdin")
+
+	proc, err := os.StartProcess(exePath, args, &os.ProcAttr{
+		Dir:   "/",
+		Env:   os.Environ(),
+		Files: []*os.File{stdin, out, out},
+		Sys:   &syscall.SysProcAttr{Setsid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToFork, err)
+	}
+	log.Debugf("Process started with PID:%d", proc.Pid)
+
+	// Writing fails rather than blocks if the daemon exits without reading, once
+	// the read end is closed here too.
+	stdin.Close()
+	if _, err := io.WriteString(pathsOut, strings.Join(paths, "\x00")); err != nil {
+		log.Debugf("Unable to pass the paths to the daemon: %v", err)
+	}
+	pathsOut.Close()
+
+	exited := make(chan struct{})
+	go func() {
+		proc.Wait()
+		close(exited)
+	}()
+
+	deadline := time.After(daemonStartTimeout)
+	for {
+		if conn, err := net.Dial(client.Network, socketPath); err == nil {
+			conn.Close()
+			fmt.Printf("[PID:%d] Playing\n", proc.Pid)
+			return nil
 		}
 
-		fmt.Printf("[PID:%d] Playing\n", pid)
-		log.Debugf("Process forked with PID:%d", pid)
-		os.Exit(0)
+		select {
+		case <-exited:
+			return fmt.Errorf("%w: daemon exited, see %s", ErrDaemonStart, logPath)
+		case <-deadline:
+			return fmt.Errorf("%w: daemon didn't respond in %v, see %s", ErrDaemonStart, daemonStartTimeout, logPath)
+		case <-time.After(50 * time.Millisecond):
+		}
 	}
+}
 
+// RunDaemon initializes the player server in the current process and manages
+// playback of the specified target audio files and playlists until it is stopped.
+func RunDaemon(targetPaths []string) error {
 	done := make(chan struct{})
 
-	f, err := os.Create(lockFile)
+	lock, err := acquireLock(lockFile)
 	if err != nil {
-		log.Error(err)
-	} else {
-		f.Close()
-		log.Debug("Create lockfile")
+		log.Errorf("Unable to lock %s: %v", lockFile, err)
+		return err
 	}
+	log.Debug("Create lockfile")
+	defer func() {
+		if err := releaseLock(lock); err != nil {
+			log.Errorf("Unable to remove lock file: %v", err)
+		} else {
+			log.Debug("Remove lockfile")
+		}
+	}()
+
+	// Catch termination signals as early as possible, so that the deferred
+	// cleanup runs when the daemon is killed.
+	sigs := notifySignals()
+	defer signal.Stop(sigs)
 
-	playerConf, err := conf.Load()
+	confPath, playerConf, err := loadConfig()
 	if err != nil {
-		log.Debug(err)
-	} else {
-		log.Debug("Read config file")
+		return err
 	}
 
-	if playerConf == nil {
-		log.Debug("Create new config file")
-		playerConf, err = conf.CreateDefault()
+	// Named instances remember their volume level in a separate state file.
+	var stateFile string
+	if instanceName != "" {
+		stateFile, err = statePath()
 		if err != nil {
-			fmt.Printf("Unable to create default config file: %v\n", err)
+			log.Error(err)
+		} else if st, err := conf.LoadState(stateFile); err == nil {
+			playerConf.VolLevel = st.VolLevel
+			log.Debug("Read state file")
 		}
 	}
 
@@ -93,23 +168,44 @@ func RunDaemon(targetPath string) error {
 	bufferSize := sampleRate.N(time.Second / 10)
 
 	srv := NewPlayerServer(playerConf.PlaylistDir)
-	srv.Queue(&targetPath, &struct{}{})
-	go srv.ready()
+	srv.PID = os.Getpid()
+	srv.nowPlaying = newNowPlaying(playerConf)
+	srv.conf = playerConf
+	srv.confPath = confPath
+	for _, p := range targetPaths {
+		if err := srv.Queue(&protocol.QueueArgs{Path: p}, &protocol.Empty{}); err != nil {
+			log.Errorf("Unable to queue %s: %v", p, err)
+		}
+	}
+	if srv.currentSong == nil {
+		return ErrNoPlayableFiles
+	}
+	srv.ready()
 
 	defer func() {
-		playerConf.VolLevel = mapVolumeToScale(srv.vol.Volume)
-		conf.Write(playerConf)
-
-		err := os.Remove(lockFile)
-		if err != nil {
-			log.Errorf("Unable to remove lock file: %v", err)
-		} else {
-			log.Debug("Remove lockfile")
+		volLevel := mapVolumeToScale(srv.vol.Volume)
+		if instanceName == "" {
+			// Only the volume is merged into the config file,
+			// so that the changes made while playing are kept.
+			cfg := *srv.config()
+			cfg.VolLevel = volLevel
+			if err := conf.UpdateFile(confPath, &cfg, volumeKey); err != nil {
+				log.Errorf("Unable to save volume level: %v", err)
+			}
+		} else if stateFile != "" {
+			if err := conf.WriteState(stateFile, &conf.State{VolLevel: volLevel}); err != nil {
+				log.Errorf("Unable to write state file: %v", err)
+			}
 		}
+
+		srv.updateNowPlaying(nil)
 	}()
 
+	// Holding the lock guarantees that a socket left at this path is stale.
+	removeStale(socketPath, "socket")
+
 	rpc.Register(srv)
-	listener, err := net.Listen("unixpacket", "/tmp/scythix.sock")
+	listener, err := net.Listen(client.Network, socketPath)
 	if err != nil {
 		return err
 	}
@@ -125,17 +221,23 @@ func RunDaemon(targetPath string) error {
 				log.Error(err)
 				return
 			}
-			rpc.ServeConn(conn)
+			go rpc.ServeConn(conn)
 		}
 	}()
 
 	speaker.Init(sampleRate, bufferSize)
+	defer srv.closeQueue()
+	go srv.handleSignals(sigs)
+	go srv.watchConfig()
+	srv.setLibraryWatch(playerConf.LibraryWatch)
 
 	for {
 		select {
 		case song, ok := <-srv.nextSong():
 			if ok {
-				defer song.Streamer.Close()
+				// The status is taken along with the new streamers, since the
+				// current song may change again as soon as the speaker is unlocked.
+				speaker.Lock()
 				srv.ctrl = &beep.Ctrl{Streamer: beep.Loop(1, song.Streamer), Paused: false}
 				srv.vol = &effects.Volume{
 					Streamer: srv.ctrl,
@@ -143,18 +245,24 @@ func RunDaemon(targetPath string) error {
 					Volume:   currentVol,
 					Silent:   srv.vol.Silent,
 				}
-				resampled := beep.Resample(4, srv.currentSong.Format.SampleRate, sampleRate, srv.vol)
+				st := srv.songStatus(song)
+				speaker.Unlock()
+				resampled := beep.Resample(4, song.Format.SampleRate, sampleRate, srv.vol)
 				speaker.Play(beep.Seq(resampled, beep.Callback(func() {
 					currentVol = srv.vol.Volume
 					srv.currentSong = srv.currentSong.Next
 					srv.ready()
 				})))
+				srv.updateNowPlaying(song)
+				srv.events.publish(protocol.EventTrack, st)
 			} else {
 				close(done)
+				srv.events.publish(protocol.EventStop, protocol.Status{})
 				return nil
 			}
 		case <-srv.done:
 			close(done)
+			srv.events.publish(protocol.EventStop, protocol.Status{})
 			log.Debug("Player stopped.")
 			return nil
 		}
@@ -5,4 +5,11 @@ import "fmt"
 var (
 	ErrNoFilePath   = fmt.Errorf("file not specified")
 	ErrFailedToFork = fmt.Errorf("failed to fork process")
+	ErrDaemonStart  = fmt.Errorf("failed to start daemon")
+
+	ErrNoPlayableFiles = fmt.Errorf("no playable files")
+	ErrInvalidIndex    = fmt.Errorf("no such track in the queue")
+	ErrNoMatches       = fmt.Errorf("no tracks in the library match the query")
+	ErrNoAlbum         = fmt.Errorf("no such album in the library")
+	ErrNoSmartPlaylist = fmt.Errorf("no such smart playlist")
 )
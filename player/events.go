@@ -0,0 +1,59 @@
+package player
+
+import (
+	"sync"
+	"time"
+
+	"scythix/protocol"
+)
+
+// eventWaitTimeout limits how long a WaitEvent call blocks, so that clients
+// can notice a broken connection.
+const eventWaitTimeout = 30 * time.Second
+
+// eventBus keeps the last published event and wakes up the clients waiting for it.
+type eventBus struct {
+	mu      sync.Mutex
+	last    protocol.Event
+	changed chan struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{changed: make(chan struct{})}
+}
+
+// publish stores the event under the next sequence number and notifies the waiters.
+func (b *eventBus) publish(kind string, status protocol.Status) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.last = protocol.Event{Seq: b.last.Seq + 1, Kind: kind, Status: status}
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// wait returns the last event once its sequence number is greater than since.
+// If no such event is published before the timeout expires or the done channel
+// is closed, an event without kind is returned.
+func (b *eventBus) wait(since uint64, timeout time.Duration, done <-chan struct{}) protocol.Event {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		b.mu.Lock()
+		last, changed := b.last, b.changed
+		b.mu.Unlock()
+
+		if last.Seq > since {
+			return last
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return protocol.Event{Seq: since}
+		case <-done:
+			return protocol.Event{Seq: since}
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package player
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"scythix/env"
+	"scythix/library"
+	"scythix/protocol"
+	"scythix/trackfmt"
+)
+
+// defaultSearchFormat is the format template of the search results unless -format is given.
+const defaultSearchFormat = "%index%. %artist% – %title% (%album%) [%duration%]  %path%"
+
+// libraryUsage describes the subcommands of the library command.
+const libraryUsage = "Manage the music library, which search and play -query use to find tracks.\n\n" +
+	"  scan [-full] [DIR...]  Index the supported audio files found in the directories\n" +
+	"                         recursively, by default the directories scanned before.\n" +
+	"                         Files unchanged since the last scan are skipped unless\n" +
+	"                         -full is given\n" +
+	"  info                   Display the scanned directories and the number of tracks\n\n" +
+	"With library_watch = true in the config file, the running player keeps the library\n" +
+	"up to date as files are added, changed, moved and removed."
+
+// searchUsage describes the query language of the search command and the -query flag.
+const searchUsage = "Search the music library for tracks matching the query.\n\n" +
+	"A query is made of words which must all match. A word of the form field:value\n" +
+	"matches the field, any other word matches the title, artist, album, album artist,\n" +
+	"composer or file name. Text matches ignore case, values with spaces are quoted.\n" +
+	"The year, track and disc take a number or a range, e.g. year:1970-1979.\n\n" +
+	"Example: scythix search 'artist:bowie year:1977'"
+
+// lsUsage describes the subcommands of the ls command.
+const lsUsage = "List the artists, albums or genres of the music library.\n\n" +
+	"  artists                  The artists of the tracks\n" +
+	"  albums [-artist ARTIST]  The albums, of the artists whose name contains ARTIST if given\n" +
+	"  genres                   The genres of the tracks\n\n" +
+	"Use play -album TITLE to play an album in track order."
+
+// openLibrary reads the library database from the data directory.
+func openLibrary() (*library.Library, error) {
+	dbPath, err := library.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	return library.Open(dbPath)
+}
+
+// updateLibrary reads the library database from the data directory and locks it
+// for changes until it is closed. Unless wait is set, library.ErrLocked is returned
+// if it is being updated by another process.
+func updateLibrary(wait bool) (*library.Library, error) {
+	dbPath, err := library.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	return library.OpenForUpdate(dbPath, wait)
+}
+
+// runLibrary runs a subcommand of the library command.
+func runLibrary(args []string) error {
+	switch {
+	case len(args) >= 1 && args[0] == "scan":
+		fs := flag.NewFlagSet("library scan", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		full := fs.Bool("full", false, "")
+		if err := fs.Parse(args[1:]); err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+		return scanLibrary(fs.Args(), *full)
+	case len(args) == 1 && args[0] == "info":
+		return displayLibraryInfo()
+	}
+
+	return fmt.Errorf("%w: expected scan [-full] [DIR...] or info", ErrUsage)
+}
+
+// scanLibrary indexes the directories, the ones scanned before if none are given.
+// Unless full is set, only the files changed since the last scan are read.
+func scanLibrary(dirs []string, full bool) error {
+	lib, err := updateLibrary(false)
+	if errors.Is(err, library.ErrLocked) {
+		fmt.Fprintln(os.Stderr, "scythix library: waiting for another update of the library to finish")
+		lib, err = updateLibrary(true)
+	}
+	if err != nil {
+		return err
+	}
+	defer lib.Close()
+
+	if len(dirs) == 0 {
+		dirs = lib.Roots
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("%w: no directories scanned before, expected scan DIR...", ErrUsage)
+	}
+
+	var errs []error
+	for _, dir := range dirs {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("%w: %s is not a directory", env.ErrInvalidPath, dir))
+			continue
+		}
+
+		stats, err := lib.Scan(dir, full, func(path string, err error) {
+			log.Debugf("Unable to index %s: %v", path, err)
+			fmt.Fprintf(os.Stderr, "scythix library: unable to index %s: %v\n", path, err)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to scan %s: %w", dir, err))
+			continue
+		}
+		fmt.Printf("%s: %d added, %d updated, %d unchanged, %d removed, %d failed\n",
+			dir, stats.Added, stats.Updated, stats.Unchanged, stats.Removed, stats.Failed)
+	}
+
+	if err := lib.Save(); err != nil {
+		return fmt.Errorf("unable to save the library: %w", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// displayLibraryInfo prints the scanned directories and the number of tracks.
+func displayLibraryInfo() error {
+	lib, err := openLibrary()
+	if err != nil {
+		return err
+	}
+
+	var duration time.Duration
+	for _, t := range lib.Tracks {
+		duration += t.Prop.Duration
+	}
+
+	if jsonOutput {
+		return printJSON(libraryJSON{
+			Roots:    lib.Roots,
+			Tracks:   len(lib.Tracks),
+			Duration: seconds(duration),
+			Updated:  lib.Updated,
+		})
+	}
+
+	fmt.Printf("Tracks   | %d\n", len(lib.Tracks))
+	fmt.Printf("Length   | %s\n", duration.Round(time.Second))
+	if !lib.Updated.IsZero() {
+		fmt.Printf("Updated  | %s\n", lib.Updated.Format(time.DateTime))
+	}
+	for _, root := range lib.Roots {
+		fmt.Printf("Scanned  | %s\n", root)
+	}
+
+	return nil
+}
+
+// searchLibrary returns the tracks of the library matching the query.
+func searchLibrary(text string) ([]*library.Track, error) {
+	query, err := library.ParseQuery(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	lib, err := openLibrary()
+	if err != nil {
+		return nil, err
+	}
+	if len(lib.Tracks) == 0 {
+		return nil, library.ErrNoLibrary
+	}
+
+	return lib.Search(query), nil
+}
+
+// runSearch prints the tracks of the library matching the query given in the arguments.
+func runSearch(args []string) error {
+	tracks, err := searchLibrary(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		docs := make([]trackJSON, 0, len(tracks))
+		for i, t := range tracks {
+			docs = append(docs, newTrackJSON(libraryTrack(t, i)))
+		}
+		return printJSON(docs)
+	}
+
+	text := defaultSearchFormat
+	if formatFlag != "" {
+		text = formatFlag
+	}
+	format, err := trackfmt.Parse(text)
+	if err != nil {
+		return fmt.Errorf("%w: -format: %w", ErrUsage, err)
+	}
+
+	width := len(strconv.Itoa(len(tracks)))
+	for i, t := range tracks {
+		text, err := format.Execute(trackfmt.TrackFields(libraryTrack(t, i), width))
+		if err != nil {
+			return err
+		}
+		fmt.Println(text)
+	}
+
+	return nil
+}
+
+// libraryTrack returns the library track as the i-th entry of a track list.
+func libraryTrack(t *library.Track, i int) protocol.Track {
+	prop := t.Prop
+	return protocol.Track{Index: i + 1, Path: t.Path, Prop: &prop, Duration: prop.Duration}
+}
+
+// queryPaths returns the paths of the tracks of the library matching the query
+// given with the -query flag of play and queue.
+func queryPaths(text string) ([]string, error) {
+	tracks, err := searchLibrary(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoMatches, text)
+	}
+
+	paths := make([]string, 0, len(tracks))
+	for _, t := range tracks {
+		paths = append(paths, t.Path)
+	}
+
+	return paths, nil
+}
+
+// runLs lists the artists, albums or genres of the library.
+func runLs(args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	artist := fs.String("artist", "", "")
+	if len(args) > 0 {
+		if err := fs.Parse(args[1:]); err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+	}
+	if len(args) == 0 || fs.NArg() > 0 || (*artist != "" && args[0] != "albums") {
+		return fmt.Errorf("%w: expected artists, albums [-artist ARTIST] or genres", ErrUsage)
+	}
+
+	lib, err := openLibrary()
+	if err != nil {
+		return err
+	}
+	if len(lib.Tracks) == 0 {
+		return library.ErrNoLibrary
+	}
+
+	switch args[0] {
+	case "artists":
+		return printGroups(lib.Artists())
+	case "genres":
+		return printGroups(lib.Genres())
+	case "albums":
+		return printAlbums(lib.Albums(*artist))
+	}
+
+	return fmt.Errorf("%w: unknown subcommand %q, expected artists, albums or genres", ErrUsage, args[0])
+}
+
+// printGroups prints the artists or genres with their number of tracks.
+func printGroups(groups []library.Group) error {
+	if jsonOutput {
+		docs := make([]groupJSON, 0, len(groups))
+		for _, g := range groups {
+			docs = append(docs, groupJSON{Name: g.Name, Tracks: g.Tracks, Duration: seconds(g.Duration)})
+		}
+		return printJSON(docs)
+	}
+
+	for _, g := range groups {
+		fmt.Printf("%s (%d %s)\n", g.Name, g.Tracks, plural(g.Tracks, "track"))
+	}
+
+	return nil
+}
+
+// printAlbums prints the albums with their artist, year and number of tracks.
+func printAlbums(albums []library.Album) error {
+	if jsonOutput {
+		docs := make([]albumJSON, 0, len(albums))
+		for _, a := range albums {
+			docs = append(docs, albumJSON{
+				Title:    a.Title,
+				Artist:   a.Artist,
+				Year:     a.Year,
+				Tracks:   a.Tracks,
+				Duration: seconds(a.Duration),
+			})
+		}
+		return printJSON(docs)
+	}
+
+	for _, a := range albums {
+		year := ""
+		if a.Year != 0 {
+			year = fmt.Sprintf(" (%d)", a.Year)
+		}
+		fmt.Printf("%s – %s%s, %d %s [%s]\n", a.Artist, a.Title, year, a.Tracks, plural(a.Tracks, "track"),
+			trackfmt.Duration(a.Duration))
+	}
+
+	return nil
+}
+
+// plural returns the noun in the plural form unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
+
+// albumPaths returns the paths of the tracks of the album given with the -album
+// flag of play and queue, in track order. The artist given with -artist, if any,
+// selects one of the albums of several artists with that title.
+func albumPaths(title, artist string) ([]string, error) {
+	lib, err := openLibrary()
+	if err != nil {
+		return nil, err
+	}
+	if len(lib.Tracks) == 0 {
+		return nil, library.ErrNoLibrary
+	}
+
+	tracks, err := lib.Album(title, artist)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoAlbum, title)
+	}
+
+	paths := make([]string, 0, len(tracks))
+	for _, t := range tracks {
+		paths = append(paths, t.Path)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,409 @@
+package player
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gopxl/beep/speaker"
+	log "github.com/sirupsen/logrus"
+
+	"scythix/fswatch"
+	"scythix/library"
+	"scythix/playlist"
+	"scythix/protocol"
+)
+
+// libraryDelay is the time to wait for further changes of the watched files before
+// the library is updated, since copying or moving an album changes many files at once.
+// Files moved out of the watched directories are known to be gone once it expires.
+const libraryDelay = 2 * time.Second
+
+// Kinds of the changes applied to the library.
+const (
+	changeUpdate = iota
+	changeScan
+	changeRemove
+	changeRename
+)
+
+// libraryChange is a change of the watched files to apply to the library.
+type libraryChange struct {
+	kind int
+	path string
+	// from is the former path of a renamed file or directory.
+	from string
+}
+
+// libraryWatcher keeps the library and the queue up to date with the files in
+// the scanned directories.
+type libraryWatcher struct {
+	p      *PlayerServer
+	w      *fswatch.Watcher
+	dbPath string
+	// roots are the watched directories of the library.
+	roots   []string
+	changes []libraryChange
+	// moves holds the former paths of the files moved away, by the cookie that
+	// relates them to the event of their new path.
+	moves map[uint32]string
+}
+
+// setLibraryWatch starts or stops watching the directories of the library.
+func (p *PlayerServer) setLibraryWatch(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case enabled && p.stopLibraryWatch == nil:
+		stop := make(chan struct{})
+		p.stopLibraryWatch = stop
+		go func() {
+			p.watchLibrary(stop)
+
+			// The watcher may fail, so that setLibraryWatch can start it again.
+			p.mu.Lock()
+			if p.stopLibraryWatch == stop {
+				p.stopLibraryWatch = nil
+			}
+			p.mu.Unlock()
+		}()
+	case !enabled && p.stopLibraryWatch != nil:
+		close(p.stopLibraryWatch)
+		p.stopLibraryWatch = nil
+	}
+}
+
+// watchLibrary watches the scanned directories of the library recursively until
+// the stop channel is closed or the player server is stopped. The database is
+// watched too, so that the directories scanned later are watched as well.
+func (p *PlayerServer) watchLibrary(stop <-chan struct{}) {
+	dbPath, err := library.Path()
+	if err != nil {
+		log.Errorf("Unable to watch library: %v", err)
+		return
+	}
+
+	w, err := fswatch.New()
+	if err != nil {
+		log.Errorf("Unable to watch library: %v", err)
+		return
+	}
+	defer w.Close()
+
+	lw := &libraryWatcher{p: p, w: w, dbPath: dbPath, moves: map[uint32]string{}}
+	// The database is replaced when it is saved, so its directory is watched.
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		log.Errorf("Unable to watch library: %v", err)
+		return
+	}
+	if err := w.Add(filepath.Dir(dbPath)); err != nil {
+		log.Errorf("Unable to watch library: %v", err)
+		return
+	}
+	lw.updateRoots()
+
+	flush := time.NewTimer(libraryDelay)
+	flush.Stop()
+	defer func() {
+		if !lw.flush() {
+			log.Errorf("Unable to update library with %d changes: %v", len(lw.changes), library.ErrLocked)
+		}
+	}()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if ev.Path == dbPath {
+				if ev.Has(fswatch.MovedTo) || ev.Has(fswatch.Write) {
+					lw.updateRoots()
+				}
+				continue
+			}
+			lw.handle(ev)
+			flush.Reset(libraryDelay)
+		case err := <-w.Errors:
+			log.Errorf("Library watcher failed: %v", err)
+			return
+		case <-flush.C:
+			if !lw.flush() {
+				flush.Reset(libraryDelay)
+			}
+		case <-stop:
+			log.Debug("Library watcher stopped")
+			return
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// updateRoots watches the directories scanned since the library was last read
+// and stops watching the directories that are no longer part of it.
+func (lw *libraryWatcher) updateRoots() {
+	lib, err := openLibrary()
+	if err != nil {
+		log.Errorf("Unable to read library: %v", err)
+		return
+	}
+	if slices.Equal(lib.Roots, lw.roots) {
+		return
+	}
+
+	for _, root := range lw.roots {
+		if !slices.ContainsFunc(lib.Roots, func(r string) bool { return inPath(root, r) }) {
+			lw.w.Remove(root)
+		}
+	}
+	for _, root := range lib.Roots {
+		if !slices.Contains(lw.roots, root) {
+			lw.addWatches(root)
+		}
+	}
+	lw.roots = lib.Roots
+	// Removing a directory may have removed the watch of the database too.
+	if err := lw.w.Add(filepath.Dir(lw.dbPath)); err != nil {
+		log.Errorf("Unable to watch library: %v", err)
+	}
+
+	if len(lw.roots) == 0 {
+		log.Debug("No library directories to watch, waiting for a scan")
+		return
+	}
+	log.Debugf("Watching library directories %s", strings.Join(lw.roots, ", "))
+}
+
+// addWatches watches the directory and its subdirectories.
+func (lw *libraryWatcher) addWatches(dir string) {
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if err := lw.w.Add(p); err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				log.Errorf("Unable to watch %s, raise the fs.inotify.max_user_watches limit: %v", p, err)
+				return filepath.SkipAll
+			}
+			log.Errorf("Unable to watch %s: %v", p, err)
+		}
+		return nil
+	})
+}
+
+// handle records the change of the event and applies it to the queue.
+func (lw *libraryWatcher) handle(ev fswatch.Event) {
+	switch {
+	case ev.Has(fswatch.Overflow):
+		lw.overflow()
+	case ev.Has(fswatch.MovedFrom):
+		lw.moves[ev.Cookie] = ev.Path
+	case ev.Has(fswatch.MovedTo):
+		from, ok := lw.moves[ev.Cookie]
+		delete(lw.moves, ev.Cookie)
+		if ok {
+			lw.moved(from, ev.Path, ev.IsDir)
+		} else {
+			lw.added(ev.Path, ev.IsDir)
+		}
+	case ev.Has(fswatch.Create) && ev.IsDir:
+		lw.added(ev.Path, true)
+	case ev.Has(fswatch.Write) && !ev.IsDir:
+		lw.added(ev.Path, false)
+	case ev.Has(fswatch.Remove) || ev.Has(fswatch.RemoveSelf):
+		lw.removed(ev.Path)
+	}
+}
+
+// overflow records a scan of every directory of the library, since changes of
+// the files may have been missed.
+func (lw *libraryWatcher) overflow() {
+	log.Errorf("Library watcher missed changes, rescanning %s", strings.Join(lw.roots, ", "))
+	for _, root := range lw.roots {
+		lw.addWatches(root)
+		lw.changes = append(lw.changes, libraryChange{kind: changeScan, path: root})
+	}
+}
+
+// added records a file that was written or a directory that appeared.
+func (lw *libraryWatcher) added(path string, isDir bool) {
+	if isDir {
+		lw.addWatches(path)
+		lw.changes = append(lw.changes, libraryChange{kind: changeScan, path: path})
+		return
+	}
+	if !playlist.IsAudioFile(path) {
+		return
+	}
+
+	lw.changes = append(lw.changes, libraryChange{kind: changeUpdate, path: path})
+	lw.p.reloadQueued(path)
+}
+
+// removed records a file or directory that was deleted or moved away.
+func (lw *libraryWatcher) removed(path string) {
+	lw.w.Remove(path)
+	lw.changes = append(lw.changes, libraryChange{kind: changeRemove, path: path})
+	lw.p.dropQueued(path)
+}
+
+// moved records a file or directory that was renamed within the watched directories.
+func (lw *libraryWatcher) moved(from, to string, isDir bool) {
+	if isDir {
+		lw.w.Remove(from)
+		lw.addWatches(to)
+		lw.changes = append(lw.changes, libraryChange{kind: changeRename, path: to, from: from})
+		lw.p.renameQueued(from, to)
+		return
+	}
+
+	// A file may be renamed from or to a name of another type, e.g. a temporary
+	// file replacing an audio file.
+	switch fromAudio, toAudio := playlist.IsAudioFile(from), playlist.IsAudioFile(to); {
+	case fromAudio && toAudio:
+		lw.changes = append(lw.changes, libraryChange{kind: changeRename, path: to, from: from})
+		lw.p.renameQueued(from, to)
+		lw.p.reloadQueued(to)
+	case fromAudio:
+		lw.removed(from)
+	case toAudio:
+		lw.added(to, false)
+	}
+}
+
+// flush applies the recorded changes to the library. The files moved away whose
+// new path wasn't reported have left the watched directories. It returns false
+// if the library is being updated by another process, so that the changes are
+// applied later.
+func (lw *libraryWatcher) flush() bool {
+	for cookie, from := range lw.moves {
+		delete(lw.moves, cookie)
+		lw.removed(from)
+	}
+	if len(lw.changes) == 0 {
+		return true
+	}
+
+	// The library is read again, since it may have been scanned in the meantime.
+	lib, err := updateLibrary(false)
+	if errors.Is(err, library.ErrLocked) {
+		log.Debugf("Library is being updated, %d changes postponed", len(lw.changes))
+		return false
+	}
+	if err != nil {
+		log.Errorf("Unable to update library: %v", err)
+		return true
+	}
+	defer lib.Close()
+	for _, c := range lw.changes {
+		switch c.kind {
+		case changeUpdate:
+			if err := lib.Update(c.path); err != nil {
+				log.Debugf("Unable to index %s: %v", c.path, err)
+				lib.Remove(c.path)
+			}
+		case changeScan:
+			if _, err := lib.Scan(c.path, false, func(path string, err error) {
+				log.Debugf("Unable to index %s: %v", path, err)
+			}); err != nil {
+				log.Debugf("Unable to scan %s: %v", c.path, err)
+			}
+		case changeRemove:
+			lib.Remove(c.path)
+		case changeRename:
+			lib.Rename(c.from, c.path)
+		}
+	}
+	if err := lib.Save(); err != nil {
+		log.Errorf("Unable to save library: %v", err)
+		return true
+	}
+
+	log.Debugf("Library updated with %d changes, %d tracks", len(lw.changes), len(lib.Tracks))
+	lw.changes = nil
+
+	return true
+}
+
+// inPath reports whether the file is the given file or inside the given directory.
+func inPath(file, path string) bool {
+	return file == path || library.InDir(file, path)
+}
+
+// reloadQueued reads the tags of the queued songs of the file again.
+func (p *PlayerServer) reloadQueued(path string) {
+	var reply protocol.ReloadTagsReply
+	if err := p.ReloadTags(&protocol.ReloadTagsArgs{Path: path}, &reply); err != nil {
+		log.Debugf("Unable to reload tags of %s: %v", path, err)
+	}
+}
+
+// dropQueued removes the queued songs of the deleted file or directory. If the
+// current song is removed, playback continues with the next one.
+func (p *PlayerServer) dropQueued(path string) {
+	speaker.Lock()
+	dropped, last := 0, false
+	for song := p.playlist.Head; song != nil; {
+		next := song.Next
+		if song != p.currentSong && inPath(song.FullPath, path) {
+			p.removeSong(song)
+			dropped++
+		}
+		song = next
+	}
+	// The current song is removed last, so that playback moves on only once.
+	if p.currentSong != nil && inPath(p.currentSong.FullPath, path) {
+		last = p.removeSong(p.currentSong)
+		dropped++
+	}
+	speaker.Unlock()
+	if dropped == 0 {
+		return
+	}
+
+	log.Debugf("Removed %d songs of %s from playlist, songs in queue: %d", dropped, path, p.playlist.Size())
+	if last {
+		p.shutdown()
+		return
+	}
+	p.publish(protocol.EventQueue)
+}
+
+// renameQueued updates the paths of the queued songs of the renamed file or directory.
+// The songs keep playing, since the files stay open.
+func (p *PlayerServer) renameQueued(from, to string) {
+	speaker.Lock()
+	var current *playlist.Song
+	renamed := 0
+	for song := p.playlist.Head; song != nil; song = song.Next {
+		if !inPath(song.FullPath, from) {
+			continue
+		}
+		song.FullPath = to + strings.TrimPrefix(song.FullPath, from)
+		if song.Prop != nil {
+			prop := *song.Prop
+			prop.FileName = filepath.Base(song.FullPath)
+			song.Prop = &prop
+		}
+		renamed++
+		if song == p.currentSong {
+			current = song
+		}
+	}
+	speaker.Unlock()
+	if renamed == 0 {
+		return
+	}
+
+	log.Debugf("Renamed %d songs of %s to %s", renamed, from, to)
+	if current != nil {
+		p.updateNowPlaying(current)
+	}
+	p.publish(protocol.EventQueue)
+}
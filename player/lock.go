@@ -0,0 +1,166 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrAlreadyRunning = fmt.Errorf("player server is already running")
+
+// lockAttempts is the number of times acquireLock tries to take a lock held by another process.
+const lockAttempts = 5
+
+// acquireLock creates the lock file, takes an exclusive advisory lock on it and
+// records the PID of the current process. The returned file must be kept open
+// while the daemon runs, since closing it releases the lock.
+func acquireLock(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := flockRetry(f); err != nil {
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, ErrAlreadyRunning
+			}
+			return nil, err
+		}
+
+		// A daemon shutting down removes the file while holding the lock, so the
+		// lock may have been taken on a file that is no longer at the path.
+		same, err := lockedFileAt(f, path)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if !same {
+			f.Close()
+			continue
+		}
+
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if _, err := f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		return f, nil
+	}
+}
+
+// flockRetry takes an exclusive lock on the file. Clients checking the lock hold
+// a shared lock for a moment, so the lock is tried a few times before giving up.
+func flockRetry(f *os.File) error {
+	var err error
+	for i := 0; i < lockAttempts; i++ {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return err
+}
+
+// lockedFileAt reports whether the open file is the file at the given path.
+func lockedFileAt(f *os.File, path string) (bool, error) {
+	opened, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	current, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return os.SameFile(opened, current), nil
+}
+
+// releaseLock removes the lock file and releases the lock held on it. The file
+// is removed first, so that no other process removes a lock file it doesn't hold.
+func releaseLock(f *os.File) error {
+	err := os.Remove(f.Name())
+	f.Close()
+
+	return err
+}
+
+// lockOwner returns the PID recorded in the lock file at the given path if the
+// lock is held by a running daemon. It returns 0 if there is no lock file or the
+// lock is stale, i.e. the daemon that created it is gone, and -1 if the lock is
+// held but doesn't contain a valid PID yet.
+func lockOwner(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+
+	// If the lock can be taken, no process holds it.
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if err == nil {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return 0, nil
+	}
+	if !errors.Is(err, syscall.EWOULDBLOCK) {
+		return 0, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		// The daemon may not have written its PID yet.
+		return -1, nil
+	}
+
+	return pid, nil
+}
+
+// daemonPID returns the PID of the player server owning the given lock file, as
+// reported by lockOwner, or 0 if the server is not running. A lock file and a
+// socket left behind by a crashed daemon are kept: the next daemon takes over the
+// lock file and replaces the socket once it holds the lock, whereas removing them
+// here could remove the files of a daemon that is just starting.
+func daemonPID(lockPath string) int {
+	pid, err := lockOwner(lockPath)
+	if err != nil {
+		log.Errorf("Unable to check lock file: %v", err)
+		return 0
+	}
+
+	return pid
+}
+
+// removeStale removes a file left behind by a crashed daemon, if it exists.
+// It must only be called while holding the lock.
+func removeStale(path, kind string) {
+	err := os.Remove(path)
+	switch {
+	case err == nil:
+		log.Debugf("Removed stale %s %s", kind, path)
+	case !errors.Is(err, os.ErrNotExist):
+		log.Errorf("Unable to remove stale %s: %v", kind, err)
+	}
+}
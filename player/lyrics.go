@@ -0,0 +1,130 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"scythix/client"
+	"scythix/lyrics"
+	"scythix/protocol"
+)
+
+// lyricsPollInterval is the longest time lyrics -follow waits before it checks
+// the playback position again, e.g. to notice a seek or a new track.
+const lyricsPollInterval = time.Second
+
+// printLyrics prints the full text of the lyrics of the current track.
+func printLyrics(ctx context.Context, c *client.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	reply, err := c.Lyrics(ctx)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(newLyricsJSON(reply))
+	}
+	fmt.Println((&lyrics.Lyrics{Lines: reply.Lines}).Text())
+
+	return nil
+}
+
+// followLyrics prints the line of the lyrics sung at the playback position
+// whenever it changes, until the player stops. Unsynchronized lyrics are printed
+// in full when their track starts.
+func followLyrics(ctx context.Context, c *client.Client) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Events wake the loop up at once on a seek, a pause or a track change.
+	events := make(chan struct{}, 1)
+	stopped := make(chan struct{})
+	if c.HasFeature(protocol.FeatureSubscribe) {
+		go func() {
+			c.Subscribe(ctx, func(ev protocol.Event) error {
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+				return nil
+			})
+			close(stopped)
+		}()
+	}
+
+	var (
+		path    string
+		current = -1
+		started bool
+	)
+	for {
+		callCtx, callCancel := context.WithTimeout(ctx, callTimeout)
+		reply, err := c.Lyrics(callCtx)
+		callCancel()
+		if err != nil && !errors.Is(err, protocol.ErrNoLyrics) {
+			return err
+		}
+
+		if reply.Path != path {
+			path, current = reply.Path, -1
+			if started && len(reply.Lines) > 0 && !jsonOutput {
+				fmt.Println()
+			}
+			if !reply.Synced && len(reply.Lines) > 0 {
+				started = true
+				if err := printFollowed(reply, -1); err != nil {
+					return err
+				}
+			}
+		}
+
+		wait := lyricsPollInterval
+		if reply.Synced {
+			l := &lyrics.Lyrics{Lines: reply.Lines, Synced: true}
+			if i := l.At(reply.Position); i != current {
+				current = i
+				if i >= 0 {
+					started = true
+					if err := printFollowed(reply, i); err != nil {
+						return err
+					}
+				}
+			}
+			if next := current + 1; next < len(reply.Lines) && !reply.Paused {
+				wait = min(wait, reply.Lines[next].Time-reply.Position)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-stopped:
+			return nil
+		case <-events:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// printFollowed prints the line of the lyrics at the given index, or all the
+// lines if the index is negative.
+func printFollowed(reply *protocol.LyricsReply, index int) error {
+	lines := reply.Lines
+	if index >= 0 {
+		lines = lines[index : index+1]
+	}
+	for _, line := range lines {
+		if jsonOutput {
+			if err := printJSON(lyricLineJSON{Time: seconds(line.Time), Text: line.Text}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Println(line.Text)
+	}
+
+	return nil
+}
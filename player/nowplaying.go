@@ -0,0 +1,94 @@
+package player
+
+import (
+	"encoding/json"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"scythix/conf"
+	"scythix/env"
+	"scythix/playlist"
+	"scythix/trackfmt"
+)
+
+// nowPlaying keeps text and JSON files describing the current track up to date,
+// so that external programs (e.g. streaming overlays) can display it.
+type nowPlaying struct {
+	textPath string
+	jsonPath string
+	format   *trackfmt.Format
+}
+
+// newNowPlaying creates a now-playing writer configured by the player settings.
+func newNowPlaying(cfg *conf.Config) *nowPlaying {
+	n := &nowPlaying{
+		textPath: cfg.NowPlayingFile,
+		jsonPath: cfg.NowPlayingJSON,
+	}
+
+	// The template is checked when the config is validated.
+	text := cfg.NowPlayingTemplate
+	if text == "" {
+		text = conf.DefaultNowPlayingTemplate
+	}
+	format, err := trackfmt.Parse(text)
+	if err != nil {
+		log.Errorf("Invalid now_playing_template, using the default: %v", err)
+		format, _ = trackfmt.Parse(conf.DefaultNowPlayingTemplate)
+	}
+	n.format = format
+
+	return n
+}
+
+// enabled reports whether any now-playing file is configured.
+func (n *nowPlaying) enabled() bool {
+	return n.textPath != "" || n.jsonPath != ""
+}
+
+// nowPlayingInfo is the document written to the JSON now-playing file.
+type nowPlayingInfo struct {
+	Playing bool   `json:"playing"`
+	Path    string `json:"path,omitempty"`
+	*playlist.AudioProperties
+	Duration seconds `json:"duration,omitempty"`
+}
+
+// update writes the given song to the configured now-playing files.
+// A nil song means that nothing is playing and the files are cleared.
+func (n *nowPlaying) update(song *playlist.Song) error {
+	if n.textPath != "" {
+		var text string
+		if song != nil {
+			fields := trackfmt.Fields(song.Prop)
+			fields["path"] = song.FullPath
+			var err error
+			if text, err = n.format.Execute(fields); err != nil {
+				return err
+			}
+		}
+		if err := env.WriteFileAtomic(n.textPath, []byte(text), 0644); err != nil {
+			return err
+		}
+	}
+
+	if n.jsonPath != "" {
+		info := nowPlayingInfo{}
+		if song != nil {
+			info.Playing = true
+			info.Path = song.FullPath
+			info.AudioProperties = song.Prop
+			info.Duration = seconds(song.Prop.Duration.Round(time.Second))
+		}
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := env.WriteFileAtomic(n.jsonPath, append(b, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package player
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"scythix/playlist"
+	"scythix/protocol"
+	"scythix/trackfmt"
+)
+
+var ErrUnsupported = fmt.Errorf("not supported by the player server, restart it with the current version")
+
+// jsonOutput is set by the -json flag. Query commands print their results as
+// JSON documents instead of text, so that scripts don't have to parse the text.
+var jsonOutput bool
+
+// formatFlag is set by the -format flag. It overrides the format template
+// configured for the display command.
+var formatFlag string
+
+// displayFormat returns the format template of the display command: the one given
+// with the -format flag, or the one set with the config key.
+func displayFormat(key string) (*trackfmt.Format, error) {
+	if formatFlag != "" {
+		format, err := trackfmt.Parse(formatFlag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: -format: %w", ErrUsage, err)
+		}
+		return format, nil
+	}
+
+	cfg, _, err := effectiveConfig()
+	if err != nil {
+		return nil, err
+	}
+	text, err := cfg.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return trackfmt.Parse(text)
+}
+
+// displayStatus prints the player state through the format template set with the config key.
+func displayStatus(st *protocol.Status, key string) error {
+	format, err := displayFormat(key)
+	if err != nil {
+		return err
+	}
+
+	text, err := format.Execute(trackfmt.StatusFields(st))
+	if err != nil {
+		return err
+	}
+	fmt.Println(text)
+
+	return nil
+}
+
+// displayTracks prints every entry of the playback queue through the list format template.
+func displayTracks(reply *protocol.TracksReply) error {
+	format, err := displayFormat("list_format")
+	if err != nil {
+		return err
+	}
+
+	width := len(strconv.Itoa(len(reply.Tracks)))
+	for _, t := range reply.Tracks {
+		text, err := format.Execute(trackfmt.TrackFields(t, width))
+		if err != nil {
+			return err
+		}
+		fmt.Println(text)
+	}
+
+	return nil
+}
+
+// printJSON prints the value as a single line JSON document.
+func printJSON(v any) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// seconds is a duration encoded as a number of seconds in JSON documents.
+type seconds time.Duration
+
+func (s seconds) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(time.Duration(s).Seconds(), 'f', -1, 64)), nil
+}
+
+// trackJSON is an entry of the playback queue in JSON output.
+type trackJSON struct {
+	Index   int    `json:"index"`
+	Current bool   `json:"current"`
+	Path    string `json:"path"`
+	*playlist.AudioProperties
+	Duration seconds `json:"duration"`
+}
+
+func newTrackJSON(t protocol.Track) trackJSON {
+	return trackJSON{
+		Index:           t.Index,
+		Current:         t.Current,
+		Path:            t.Path,
+		AudioProperties: t.Prop,
+		Duration:        seconds(t.Duration),
+	}
+}
+
+// queueJSON is the playback queue in JSON output.
+type queueJSON struct {
+	Current int         `json:"current"`
+	Tracks  []trackJSON `json:"tracks"`
+}
+
+func newQueueJSON(reply *protocol.TracksReply) queueJSON {
+	q := queueJSON{Current: reply.Current, Tracks: make([]trackJSON, 0, len(reply.Tracks))}
+	for _, t := range reply.Tracks {
+		q.Tracks = append(q.Tracks, newTrackJSON(t))
+	}
+
+	return q
+}
+
+// statusJSON is the player state in JSON output.
+type statusJSON struct {
+	Playing   bool                      `json:"playing"`
+	Paused    bool                      `json:"paused"`
+	Muted     bool                      `json:"muted"`
+	Volume    float64                   `json:"volume"`
+	Index     int                       `json:"index"`
+	QueueSize int                       `json:"queue_size"`
+	Path      string                    `json:"path,omitempty"`
+	Track     *playlist.AudioProperties `json:"track,omitempty"`
+	Position  seconds                   `json:"position"`
+	Duration  seconds                   `json:"duration"`
+}
+
+func newStatusJSON(st *protocol.Status) *statusJSON {
+	return &statusJSON{
+		Playing:   st.Playing,
+		Paused:    st.Paused,
+		Muted:     st.Muted,
+		Volume:    st.Volume,
+		Index:     st.Index,
+		QueueSize: st.QueueSize,
+		Path:      st.Path,
+		Track:     st.Track,
+		Position:  seconds(st.Position),
+		Duration:  seconds(st.Duration),
+	}
+}
+
+// coverJSON describes the exported cover art in JSON output.
+type coverJSON struct {
+	Path     string `json:"path"`
+	MIMEType string `json:"mime_type"`
+	Size     int    `json:"size"`
+	Source   string `json:"source,omitempty"`
+}
+
+// lyricsJSON is the lyrics of the current track in JSON output.
+type lyricsJSON struct {
+	Path   string          `json:"path"`
+	Source string          `json:"source"`
+	Synced bool            `json:"synced"`
+	Lines  []lyricLineJSON `json:"lines"`
+}
+
+// lyricLineJSON is a line of the lyrics in JSON output.
+type lyricLineJSON struct {
+	Time seconds `json:"time"`
+	Text string  `json:"text"`
+}
+
+func newLyricsJSON(reply *protocol.LyricsReply) lyricsJSON {
+	l := lyricsJSON{Path: reply.Path, Source: reply.Source, Synced: reply.Synced}
+	for _, line := range reply.Lines {
+		l.Lines = append(l.Lines, lyricLineJSON{Time: seconds(line.Time), Text: line.Text})
+	}
+
+	return l
+}
+
+// tagsJSON is the tags of an audio file in JSON output.
+type tagsJSON struct {
+	Path   string            `json:"path"`
+	Format string            `json:"format"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// libraryJSON describes the music library in JSON output.
+type libraryJSON struct {
+	Roots    []string  `json:"roots"`
+	Tracks   int       `json:"tracks"`
+	Duration seconds   `json:"duration"`
+	Updated  time.Time `json:"updated"`
+}
+
+// groupJSON is an artist or a genre of the music library in JSON output.
+type groupJSON struct {
+	Name     string  `json:"name"`
+	Tracks   int     `json:"tracks"`
+	Duration seconds `json:"duration"`
+}
+
+// albumJSON is an album of the music library in JSON output.
+type albumJSON struct {
+	Title    string  `json:"title"`
+	Artist   string  `json:"artist"`
+	Year     int     `json:"year,omitempty"`
+	Tracks   int     `json:"tracks"`
+	Duration seconds `json:"duration"`
+}
+
+// versionJSON describes the executable and the running daemon in JSON output.
+type versionJSON struct {
+	Version         string      `json:"version"`
+	ProtocolVersion int         `json:"protocol_version"`
+	Daemon          *daemonJSON `json:"daemon,omitempty"`
+}
+
+type daemonJSON struct {
+	Version         string   `json:"version,omitempty"`
+	ProtocolVersion int      `json:"protocol_version,omitempty"`
+	PID             int      `json:"pid,omitempty"`
+	Formats         []string `json:"formats,omitempty"`
+	Features        []string `json:"features,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// instanceJSON is a running player instance in JSON output.
+type instanceJSON struct {
+	Name   string      `json:"name"`
+	PID    int         `json:"pid"`
+	State  string      `json:"state"`
+	Status *statusJSON `json:"status,omitempty"`
+}
@@ -0,0 +1,170 @@
+package player
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"scythix/conf"
+	"scythix/env"
+)
+
+const (
+	filePrefix = "scythix"
+	socketExt  = ".sock"
+	lockExt    = ".lock"
+	logExt     = ".log"
+	stateExt   = ".toml"
+)
+
+// defaultInstance is the name under which the instance started without the -instance flag is listed.
+const defaultInstance = "default"
+
+var ErrInvalidInstance = fmt.Errorf("invalid instance name")
+
+var instanceNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// confFile is the location of the config file, resolved by setConfigPath.
+var confFile string
+
+// setConfigPath resolves the location of the config file. It is taken from the
+// -config-file flag, then from the SCYTHIX_CONFIG environment variable, and
+// defaults to the user's config directory.
+func setConfigPath(flagPath string) error {
+	p := flagPath
+	if p == "" {
+		var err error
+		if p, err = conf.Path(); err != nil {
+			return err
+		}
+	}
+
+	p, err := normalizePath(p)
+	if err != nil {
+		return err
+	}
+	confFile = p
+
+	return nil
+}
+
+// Name of the player instance and locations of its control socket and lock file.
+// They are resolved by setRuntimePaths before any command is executed.
+var (
+	instanceName string
+	socketPath   string
+	lockFile     string
+)
+
+// instanceFileName returns the name of a file belonging to the given instance,
+// e.g. "scythix.sock" for the default instance or "scythix-desk.sock" for "desk".
+func instanceFileName(instance, ext string) string {
+	if instance == "" {
+		return filePrefix + ext
+	}
+	return filePrefix + "-" + instance + ext
+}
+
+// parseInstance checks that the instance name can be used as a part of a file name.
+// The name of the default instance is returned as an empty string.
+func parseInstance(instance string) (string, error) {
+	if instance == "" || instance == defaultInstance {
+		return "", nil
+	}
+	if !instanceNameRe.MatchString(instance) {
+		return "", fmt.Errorf("%w: %q, only letters, digits, '-' and '_' are allowed", ErrInvalidInstance, instance)
+	}
+	return instance, nil
+}
+
+// setRuntimePaths resolves the locations of the control socket and the lock file
+// of the given instance. The socket path is taken from the -socket flag, then from
+// the config file (for the default instance only), and defaults to the per-user
+// runtime directory. The lock file is placed next to the socket, so that daemons
+// listening on different sockets don't block each other.
+func setRuntimePaths(instance, flagSocket, confSocket string) error {
+	sock := flagSocket
+	if sock == "" && instance == "" {
+		sock = confSocket
+	}
+	if sock == "" {
+		dir, err := env.RuntimeDir()
+		if err != nil {
+			return err
+		}
+		sock = path.Join(dir, instanceFileName(instance, socketExt))
+	}
+
+	sock, err := normalizePath(sock)
+	if err != nil {
+		return err
+	}
+
+	instanceName = instance
+	socketPath = sock
+	lockFile = strings.TrimSuffix(sock, socketExt) + lockExt
+
+	return nil
+}
+
+// statePath returns the location of the state file of the current named instance.
+func statePath() (string, error) {
+	dir, err := env.StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, instanceFileName(instanceName, stateExt)), nil
+}
+
+// runningInstance describes a player instance found in the runtime directory.
+type runningInstance struct {
+	name   string
+	socket string
+	lock   string
+}
+
+// listInstances returns the instances that have a control socket in the
+// runtime directory, sorted by name.
+func listInstances() ([]runningInstance, error) {
+	dir, err := env.RuntimeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := []runningInstance{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type()&os.ModeSocket == 0 || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, socketExt) {
+			continue
+		}
+
+		instance := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), socketExt)
+		switch {
+		case instance == "":
+			instance = defaultInstance
+		case strings.HasPrefix(instance, "-"):
+			instance = instance[1:]
+		default:
+			continue
+		}
+
+		sock := path.Join(dir, name)
+		instances = append(instances, runningInstance{
+			name:   instance,
+			socket: sock,
+			lock:   strings.TrimSuffix(sock, socketExt) + lockExt,
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].name < instances[j].name })
+
+	return instances, nil
+}
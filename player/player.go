@@ -1,24 +1,32 @@
 package player
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"net/rpc"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"scythix/client"
 	"scythix/conf"
 	"scythix/env"
 	"scythix/playlist"
+	"scythix/protocol"
 )
 
-const (
-	logDir      = ".cache"
-	logFileName = "scythix.log"
-)
+// logPath is the location of the log file of the current player instance.
+var logPath string
+
+// callTimeout limits the time a single command may spend talking to the daemon.
+const callTimeout = 5 * time.Second
 
 // normalizePath takes a string representation of a path and returns an absolute
 // and clean representation of that path.
@@ -31,6 +39,79 @@ func normalizePath(path string) (string, error) {
 	return path, nil
 }
 
+// expandPaths returns the absolute paths of the audio files and playlists to queue.
+// Directories are replaced with the supported audio files found in them recursively,
+// ordered by directory and then by album, disc and track number.
+func expandPaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		if !env.PathExists(arg) {
+			return nil, fmt.Errorf("%w: %s", env.ErrInvalidPath, arg)
+		}
+		p, err := normalizePath(arg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get absolute path of %s: %w", arg, err)
+		}
+
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			paths = append(paths, p)
+			continue
+		}
+
+		var found []string
+		err = filepath.WalkDir(p, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && playlist.IsAudioFile(file) {
+				found = append(found, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("%w in %s", ErrNoPlayableFiles, arg)
+		}
+		paths = append(paths, sortByTrackNumber(found)...)
+	}
+
+	return paths, nil
+}
+
+// sortByTrackNumber sorts the audio files by directory, and the files of a directory
+// by their album, disc and track number tags. Files without tags keep their lexical order.
+func sortByTrackNumber(files []string) []string {
+	props := make(map[string]*playlist.AudioProperties, len(files))
+	for _, file := range files {
+		prop, err := playlist.NewAudioProperties(file)
+		if err != nil {
+			log.Debugf("Unable to read tags of %s: %v", file, err)
+		}
+		props[file] = prop
+	}
+
+	slices.SortStableFunc(files, func(a, b string) int {
+		if c := strings.Compare(filepath.Dir(a), filepath.Dir(b)); c != 0 {
+			return c
+		}
+		switch {
+		case props[a].Less(props[b]):
+			return -1
+		case props[b].Less(props[a]):
+			return 1
+		}
+		return 0
+	})
+
+	return files
+}
+
 // mapVolumeToScale maps a volume scale starting at -12 with step 0.5 to a scale
 // whose first value is 0 and step 1.
 func mapVolumeToScale(vol float64) float64 {
@@ -43,200 +124,214 @@ func mapScaleToVolume(scale float64) float64 {
 	return (scale / 2) - 12
 }
 
-// connectRPC creates an RPC client to the player server via Unix socket.
-// If the server is not running and the lock file is absent, it exits the program.
-// Otherwise, it logs the connection failure and terminates.
-func connectRPC() *rpc.Client {
-	client, err := rpc.Dial("unixpacket", "/tmp/scythix.sock")
+// connect creates a client of the player server via Unix socket.
+// If the server is not running, it returns ErrNotRunning. An error is also returned if the server speaks an
+// incompatible protocol version or can't be reached.
+func connect(ctx context.Context) (*client.Client, error) {
+	if daemonPID(lockFile) == 0 {
+		return nil, ErrNotRunning
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	c, err := client.Dial(ctx, socketPath)
 	if err != nil {
-		if !env.PathExists(lockFile) {
-			log.Debug("Player server not running")
-			os.Exit(0)
+		var verErr *client.VersionError
+		if errors.As(err, &verErr) {
+			return nil, fmt.Errorf("incompatible player server: %w", err)
+		}
+		return nil, fmt.Errorf("player server connection failed: %w", err)
+	}
+
+	return c, nil
+}
+
+// displayVersion prints the version of the executable and, if the player server
+// is running, the version and capabilities of the server.
+func displayVersion(ctx context.Context) error {
+	out := versionJSON{Version: Version, ProtocolVersion: protocol.Version}
+	if daemonPID(lockFile) != 0 {
+		out.Daemon = &daemonJSON{}
+		if c, err := client.Dial(ctx, socketPath); err != nil {
+			out.Daemon.Error = err.Error()
+		} else {
+			hello := c.Server()
+			out.Daemon = &daemonJSON{
+				Version:         hello.Version,
+				ProtocolVersion: hello.ProtocolVersion,
+				PID:             hello.PID,
+				Formats:         hello.Formats,
+				Features:        hello.Features,
+			}
+			c.Close()
+		}
+	}
+	if jsonOutput {
+		return printJSON(out)
+	}
+
+	fmt.Printf("scythix %s (protocol v%d)\n", out.Version, out.ProtocolVersion)
+	switch d := out.Daemon; {
+	case d == nil:
+	case d.Error != "":
+		fmt.Printf("daemon: %s\n", d.Error)
+	default:
+		fmt.Printf("daemon: scythix %s (protocol v%d, PID %d)\n", d.Version, d.ProtocolVersion, d.PID)
+		fmt.Printf("formats: %s\n", strings.Join(d.Formats, ", "))
+		fmt.Printf("features: %s\n", strings.Join(d.Features, ", "))
+	}
+
+	return nil
+}
+
+// displayInstances prints the player instances found in the runtime directory
+// together with their playback state.
+func displayInstances(ctx context.Context) error {
+	instances, err := listInstances()
+	if err != nil {
+		return fmt.Errorf("unable to list instances: %w", err)
+	}
+
+	out := []instanceJSON{}
+	for _, inst := range instances {
+		pid := daemonPID(inst.lock)
+		if pid == 0 {
+			continue
+		}
+
+		i := instanceJSON{Name: inst.name, PID: pid, State: "not responding"}
+		if c, err := client.Dial(ctx, inst.socket); err == nil {
+			if st, err := c.Status(ctx); err == nil {
+				i.Status = newStatusJSON(st)
+				switch {
+				case st.Paused:
+					i.State = "paused"
+				case st.Playing:
+					i.State = "playing"
+				default:
+					i.State = "stopped"
+				}
+			}
+			c.Close()
+		}
+		out = append(out, i)
+	}
+	if jsonOutput {
+		return printJSON(out)
+	}
+
+	for _, i := range out {
+		state := i.State
+		if i.Status != nil && i.Status.Playing && i.Status.Track != nil {
+			state = fmt.Sprintf("%s: %s – %s", state, i.Status.Track.Artist, i.Status.Track.Title)
 		}
-		log.Fatalf("Player server connection failed: %v", err)
+		fmt.Printf("%-16s %-8d %s\n", i.Name, i.PID, state)
 	}
 
-	return client
+	return nil
 }
 
+// Run executes the command given on the command line and exits the program
+// with its exit code.
 func Run() {
+	os.Exit(run())
+}
+
+// run parses the global flags and executes the command given on the command line.
+// The flags of the former interface, e.g. -play PATH or -next, are translated
+// to the equivalent commands. It returns the exit code.
+func run() int {
 	var (
-		path        string
-		queued      string
-		pause       bool
-		stop        bool
-		next        bool
-		rew         bool
-		mute        bool
-		turnUp      bool
-		turnDown    bool
-		vol         int
-		info        bool
-		list        bool
-		save        bool
-		playlistDir string
+		socket     string
+		instance   string
+		configFile string
 	)
 
-	flag.StringVar(&path, "play", "", "Start playing the specified audio file or playlist")
-	flag.StringVar(&queued, "queue", "", "Add specified audio file or playlist to the playback queue")
-	flag.BoolVar(&pause, "pause", false, "Pause playback")
-	flag.BoolVar(&stop, "stop", false, "Stop playback")
-	flag.BoolVar(&next, "next", false, "Next track")
-	flag.BoolVar(&rew, "rew", false, "Rewind to previous track")
-	flag.BoolVar(&mute, "mute", false, "Mute sound")
-	flag.BoolVar(&turnUp, "turn-up", false, "Increase volume")
-	flag.BoolVar(&turnDown, "turn-down", false, "Decrease volume")
-	flag.IntVar(&vol, "vol", -1, "Set volume value")
-	flag.BoolVar(&info, "info", false, "Display track info")
-	flag.BoolVar(&list, "list", false, "Display current playlist")
-	flag.BoolVar(&save, "save", false, "Save current playlist")
-	flag.StringVar(&playlistDir, "path", "-", "Specify path for saving playlist. By default, path specified in the config is used")
+	flag.StringVar(&socket, "socket", "", "Path to the control socket. By default, a socket in the user's runtime directory is used")
+	flag.StringVar(&instance, "instance", "", "Name of the player instance to run or control")
+	flag.Func("set", "Override a config key for this run, e.g. -set sample_rate=48000. May be repeated", addConfigOverride)
+	flag.BoolVar(&jsonOutput, "json", false, "Print the output of query commands as JSON")
+	flag.StringVar(&formatFlag, "format", "", "Format template for info, list and status, e.g. '%artist% - %title% [%elapsed%/%duration%]'. By default, the template from the config is used")
+	flag.StringVar(&configFile, "config-file", "", "Path to the config file. By default, $SCYTHIX_CONFIG or conf.toml in the user's config directory is used")
+	legacy := defineLegacyFlags(flag.CommandLine)
+
+	cmds := newCommands(flag.CommandLine)
+	flag.Usage = func() { printUsage(flag.CommandLine.Output(), flag.CommandLine, cmds) }
+
+	if len(os.Args) > 1 && os.Args[1] == completeCommand {
+		return runComplete(flag.CommandLine, cmds, legacy, os.Args[2:], func() error {
+			return setup(instance, socket, configFile)
+		})
+	}
 	flag.Parse()
 
-	switch {
-	case pause == true:
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.Pause", &struct{}{}, &struct{}{}); err != nil {
-			log.Error(err)
-		}
-	case stop == true:
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.Stop", &struct{}{}, &struct{}{}); err != nil {
-			log.Error(err)
-		} else {
-			fmt.Println("See you.")
-		}
-	case next == true:
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.Next", &struct{}{}, &struct{}{}); err != nil {
-			log.Error(err)
-		}
-	case rew == true:
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.Rewind", &struct{}{}, &struct{}{}); err != nil {
-			log.Error(err)
-		}
-	case mute == true:
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.Mute", &struct{}{}, &struct{}{}); err != nil {
-			log.Error(err)
-		}
-	case turnUp == true:
-		var volLvl float64
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.TurnUp", &struct{}{}, &volLvl); err != nil {
-			log.Error(err)
-		} else {
-			fmt.Printf("vol: %g\n", volLvl)
-		}
-	case turnDown == true:
-		var volLvl float64
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.TurnDown", &struct{}{}, &volLvl); err != nil {
-			log.Error(err)
-		} else {
-			fmt.Printf("vol: %g\n", volLvl)
-		}
-	case vol > -1:
-		var volLvl float64
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.SetVol", &vol, &volLvl); err != nil {
-			log.Error(err)
-		} else if float64(vol) != volLvl {
-			fmt.Printf("vol: %g\n", volLvl)
+	name, args, ok := translateLegacy(cmds, legacy, flag.Args())
+	if !ok {
+		if flag.NArg() == 0 {
+			flag.Usage()
+			return exitUsage
 		}
-	case info == true:
-		var prop playlist.AudioProperties
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.TrackInfo", &struct{}{}, &prop); err != nil {
-			log.Error(err)
-		} else {
-			prop.Display()
-		}
-	case list == true:
-		var playlist string
-		client := connectRPC()
-		defer client.Close()
-		if err := client.Call("PlayerServer.PlaylistInfo", &struct{}{}, &playlist); err != nil {
-			log.Error(err)
-		} else {
-			fmt.Println(playlist)
-		}
-	case save == true:
-		client := connectRPC()
-		defer client.Close()
-		var playlistPath string
-		if err := client.Call("PlayerServer.SavePlaylist", playlistDir, &playlistPath); err != nil {
-			log.Error(err)
-			fmt.Println(err)
-		} else {
-			fmt.Printf("Playlist saved %s\n", playlistPath)
-		}
-	case path != "":
-		if env.PathExists(path) {
-			if env.PathExists(lockFile) {
-				log.Debug("Attempt to run more then one instance of the program")
-				fmt.Println("Already in use")
-			} else {
-				path, err := normalizePath(path)
-				if err != nil {
-					log.Error(err)
-					fmt.Printf("Failed to normalize path: %v", err)
-					return
-				}
-				err = RunDaemon(path)
-				if err != nil {
-					log.Error(err)
-					fmt.Printf("Unable to run Scythix: %v", err)
-				}
-			}
-		} else {
-			log.Fatal(env.ErrInvalidPath)
-		}
-	case queued != "":
-		if ok := env.PathExists(queued); ok {
-			// If the lock file exists, then playback is on.
-			if env.PathExists(lockFile) {
-				queued, err := normalizePath(queued)
-				if err != nil {
-					log.Error(err)
-					fmt.Printf("Unable to get absolute file path: %v", err)
-					return
-				}
-				client := connectRPC()
-				defer client.Close()
-				err = client.Call("PlayerServer.Queue", &queued, &struct{}{})
-				if err != nil {
-					log.Error(err)
-					fmt.Printf("Unable to queue: %v", err)
-				}
-			}
+		name, args = flag.Arg(0), flag.Args()[1:]
+	}
+	cmd, ok := findCommand(cmds, name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "scythix: %v: %s\nRun 'scythix help' for usage.\n", ErrUnknownCommand, name)
+		return exitUsage
+	}
+
+	if err := setup(instance, socket, configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "scythix: %v\n", err)
+		if errors.Is(err, ErrUsage) {
+			return exitUsage
 		}
+		return exitError
 	}
+
+	return cmd.execute(context.Background(), args)
 }
 
-func init() {
-	homeDir, err := env.GetHomeDir()
+// setup resolves the player instance, the config file and the runtime paths
+// from the global flags, and directs the log to the file of the instance.
+func setup(instance, socket, configFile string) error {
+	instance, err := parseInstance(instance)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+	if socket != "" {
+		confOverrides = append(confOverrides, conf.Override{Key: "socket_path", Value: socket, Flag: "socket"})
+	}
+	if err := setConfigPath(configFile); err != nil {
+		return fmt.Errorf("unable to locate config file: %w", err)
+	}
+	setupLogging(instance)
+
+	var confSocket string
+	if playerConf, _, err := effectiveConfig(); err == nil {
+		confSocket = playerConf.SocketPath
+	}
+	if err := setRuntimePaths(instance, socket, confSocket); err != nil {
+		log.Error(err)
+		return fmt.Errorf("unable to set up runtime directory: %w", err)
+	}
+
+	return nil
+}
+
+// setupLogging directs the log of the given player instance to its file in the cache
+// directory and sets the log level from the config file.
+func setupLogging(instance string) {
+	logDir, err := env.CacheHome()
 	if err != nil {
 		log.Error(err)
 	}
 
-	logDir := path.Join(homeDir, logDir)
 	err = os.MkdirAll(logDir, os.ModePerm)
 	if err != nil {
 		log.Error(err)
 	}
 
-	logPath := path.Join(logDir, logFileName)
+	logPath = path.Join(logDir, instanceFileName(instance, logExt))
 	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		log.Fatal(err)
@@ -244,19 +339,26 @@ func init() {
 
 	logLevel := log.DebugLevel
 
-	if playerConf, err := conf.Load(); err == nil {
-		levelMap := map[string]log.Level{
-			"debug": log.DebugLevel,
-			"info":  log.InfoLevel,
-			"warn":  log.WarnLevel,
-			"error": log.ErrorLevel,
-			"fatal": log.FatalLevel,
-			"panic": log.PanicLevel,
-		}
-		if level, ok := levelMap[playerConf.LogLevel]; ok {
+	if playerConf, _, err := effectiveConfig(); err == nil {
+		if level, ok := parseLogLevel(playerConf.LogLevel); ok {
 			logLevel = level
 		}
 	}
 	log.SetOutput(logFile)
 	log.SetLevel(logLevel)
 }
+
+// parseLogLevel returns the log level with the given name as used in the config file.
+func parseLogLevel(name string) (log.Level, bool) {
+	levelMap := map[string]log.Level{
+		"debug": log.DebugLevel,
+		"info":  log.InfoLevel,
+		"warn":  log.WarnLevel,
+		"error": log.ErrorLevel,
+		"fatal": log.FatalLevel,
+		"panic": log.PanicLevel,
+	}
+	level, ok := levelMap[name]
+
+	return level, ok
+}
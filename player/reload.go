@@ -0,0 +1,74 @@
+package player
+
+import (
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"scythix/fswatch"
+)
+
+// volumeKey is the config key holding the volume level.
+const volumeKey = "volume_level"
+
+// reloadDelay is the time to wait for further changes of the config file before
+// reloading it, since editors often save a file in several steps.
+const reloadDelay = 200 * time.Millisecond
+
+// reloadConfig reads the config file again and applies it to the running player.
+// Values overridden by the environment and the command line keep precedence.
+func (p *PlayerServer) reloadConfig() {
+	cfg, _, err := effectiveConfig()
+	if err != nil {
+		log.Errorf("Unable to reload config file: %v", err)
+		return
+	}
+	if err := validateConfig(cfg); err != nil {
+		log.Errorf("Config file not reloaded: %v", err)
+		return
+	}
+
+	p.applyConfig(cfg)
+	log.Debug("Config file reloaded")
+}
+
+// watchConfig reloads the config file whenever it changes, until the player server
+// is stopped. The directory of the file is watched, so that files replaced by
+// editors with a rename are noticed too.
+func (p *PlayerServer) watchConfig() {
+	w, err := fswatch.New()
+	if err != nil {
+		log.Errorf("Unable to watch config file: %v", err)
+		return
+	}
+	defer w.Close()
+
+	if err := w.Add(filepath.Dir(p.confPath)); err != nil {
+		log.Errorf("Unable to watch config file: %v", err)
+		return
+	}
+
+	reload := time.NewTimer(reloadDelay)
+	reload.Stop()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			// After an overflow, the file may have changed unnoticed.
+			if ev.Has(fswatch.Overflow) ||
+				ev.Path == filepath.Clean(p.confPath) && (ev.Has(fswatch.Write) || ev.Has(fswatch.MovedTo)) {
+				reload.Reset(reloadDelay)
+			}
+		case err := <-w.Errors:
+			log.Errorf("Config file watcher failed: %v", err)
+			return
+		case <-reload.C:
+			p.reloadConfig()
+		case <-p.done:
+			return
+		}
+	}
+}
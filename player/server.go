@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gopxl/beep"
@@ -14,9 +15,12 @@ import (
 	"github.com/gopxl/beep/speaker"
 	log "github.com/sirupsen/logrus"
 
+	"scythix/conf"
 	"scythix/env"
+	"scythix/lyrics"
 	"scythix/m3u"
 	"scythix/playlist"
+	"scythix/protocol"
 )
 
 // PlayerServer represents a server for managing music playback via RPC.
@@ -25,27 +29,67 @@ type PlayerServer struct {
 	playlist    *playlist.Playlist
 	currentSong *playlist.Song
 	playlistDir string
+	nowPlaying  *nowPlaying
+	conf        *conf.Config
+	confPath    string
+	// mu guards the settings that can be changed while the player runs.
+	mu sync.Mutex
 
 	ctrl *beep.Ctrl
 	vol  *effects.Volume
 
-	done chan struct{}
+	// lyrics caches the lyrics of the track at lyricsPath, guarded by mu.
+	// They are read again if the .lrc file next to the track changes.
+	lyricsPath    string
+	lyricsSidecar string
+	lyricsModTime time.Time
+	lyrics        *lyrics.Lyrics
+
+	// stopLibraryWatch stops the library watcher if it runs, guarded by mu.
+	stopLibraryWatch chan struct{}
+
+	events   *eventBus
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Hello describes the daemon and its capabilities. Clients call it before any
+// other method to make sure they speak the same protocol version.
+func (p *PlayerServer) Hello(args *protocol.HelloArgs, reply *protocol.HelloReply) error {
+	reply.Version = Version
+	reply.ProtocolVersion = protocol.Version
+	reply.PID = p.PID
+	reply.Formats = playlist.SupportedFormats()
+	reply.Features = []string{protocol.FeatureStatus, protocol.FeatureSubscribe, protocol.FeatureConfig,
+		protocol.FeatureTracks, protocol.FeatureQueueEdit, protocol.FeatureSeek, protocol.FeatureCover, protocol.FeatureLyrics,
+		protocol.FeatureReloadTags}
+	p.mu.Lock()
+	nowPlayingEnabled := p.nowPlaying.enabled()
+	p.mu.Unlock()
+	if nowPlayingEnabled {
+		reply.Features = append(reply.Features, protocol.FeatureNowPlaying)
+	}
+
+	log.Debugf("Hello from client speaking protocol v%d", args.ProtocolVersion)
+
+	return nil
 }
 
 // Pause toggle the player's paused state.
-func (p *PlayerServer) Pause(args *struct{}, reply *struct{}) error {
+func (p *PlayerServer) Pause(args *protocol.Empty, reply *protocol.Empty) error {
 	speaker.Lock()
 	p.ctrl.Paused = !p.ctrl.Paused
 	speaker.Unlock()
 
 	log.Debug("Player paused.")
+	p.publish(protocol.EventPause)
 
 	return nil
 }
 
-// Stop halts playback and signals the daemon to finish by closing the `done` channel.
-func (p *PlayerServer) Stop(args *struct{}, reply *struct{}) error {
-	close(p.done)
+// Stop halts playback and signals the daemon to finish.
+func (p *PlayerServer) Stop(args *protocol.Empty, reply *protocol.Empty) error {
+	p.shutdown()
 
 	log.Debug("Got stop command")
 
@@ -53,7 +97,7 @@ func (p *PlayerServer) Stop(args *struct{}, reply *struct{}) error {
 }
 
 // Mute toggles the mute state of the player.
-func (p *PlayerServer) Mute(args *struct{}, reply *struct{}) error {
+func (p *PlayerServer) Mute(args *protocol.Empty, reply *protocol.Empty) error {
 	speaker.Lock()
 	p.vol.Silent = !p.vol.Silent
 	speaker.Unlock()
@@ -63,12 +107,13 @@ func (p *PlayerServer) Mute(args *struct{}, reply *struct{}) error {
 	} else {
 		log.Debug("Player unmuted.")
 	}
+	p.publish(protocol.EventVolume)
 
 	return nil
 }
 
 // TurnUp increments the player's volume by a predefined step, up to a maximum limit.
-func (p *PlayerServer) TurnUp(args *struct{}, reply *float64) error {
+func (p *PlayerServer) TurnUp(args *protocol.Empty, reply *protocol.VolumeReply) error {
 	speaker.Lock()
 	p.vol.Silent = false
 	if p.vol.Volume < volLimitMax {
@@ -76,14 +121,15 @@ func (p *PlayerServer) TurnUp(args *struct{}, reply *float64) error {
 	}
 	speaker.Unlock()
 
-	*reply = mapVolumeToScale(p.vol.Volume)
+	reply.Level = mapVolumeToScale(p.vol.Volume)
 	log.Debugf("Volume set to %g", mapVolumeToScale(p.vol.Volume))
+	p.publish(protocol.EventVolume)
 
 	return nil
 }
 
 // TurnDown decreases the player's volume by a predefined step, not going below the minimum limit.
-func (p *PlayerServer) TurnDown(args *struct{}, reply *float64) error {
+func (p *PlayerServer) TurnDown(args *protocol.Empty, reply *protocol.VolumeReply) error {
 	speaker.Lock()
 	p.vol.Silent = false
 	if p.vol.Volume > volLimitMin {
@@ -91,15 +137,16 @@ func (p *PlayerServer) TurnDown(args *struct{}, reply *float64) error {
 	}
 	speaker.Unlock()
 
-	*reply = mapVolumeToScale(p.vol.Volume)
+	reply.Level = mapVolumeToScale(p.vol.Volume)
 	log.Debugf("Volume set to %g", mapVolumeToScale(p.vol.Volume))
+	p.publish(protocol.EventVolume)
 
 	return nil
 }
 
 // SetVol sets the player's volume to a specific level, adjusting within the maximum limit.
-func (p *PlayerServer) SetVol(arg *int, reply *float64) error {
-	vol := mapScaleToVolume(float64(*arg))
+func (p *PlayerServer) SetVol(args *protocol.SetVolArgs, reply *protocol.VolumeReply) error {
+	vol := mapScaleToVolume(float64(args.Level))
 	speaker.Lock()
 	if vol > volLimitMax {
 		vol = volLimitMax
@@ -108,46 +155,69 @@ func (p *PlayerServer) SetVol(arg *int, reply *float64) error {
 	p.vol.Silent = false
 	speaker.Unlock()
 
-	*reply = mapVolumeToScale(vol)
+	reply.Level = mapVolumeToScale(vol)
 	log.Debugf("Volume turned down to %g", mapVolumeToScale(p.vol.Volume))
+	p.publish(protocol.EventVolume)
 
 	return nil
 }
 
 // Queue adds songs to the end of the playlist by its file path.
 // If the path is a .m3u or .m3u8 file, the entire playlist is loaded and queued.
-func (p *PlayerServer) Queue(targetPath *string, reply *struct{}) error {
-	if strings.HasSuffix(*targetPath, ".m3u") || strings.HasSuffix(*targetPath, ".m3u8") {
-		songs, err := m3u.Load(*targetPath)
+// If it is a .smart file, the tracks of the library it selects are queued.
+func (p *PlayerServer) Queue(args *protocol.QueueArgs, reply *protocol.Empty) error {
+	if strings.HasSuffix(args.Path, ".m3u") || strings.HasSuffix(args.Path, ".m3u8") {
+		songs, err := m3u.Load(args.Path)
 		if err != nil {
 			return err
 		}
 
-		p.playlist.Queue(songs...)
-		if p.currentSong == nil {
-			p.currentSong = p.playlist.Head
+		size := p.queueSongs(songs...)
+		log.Debugf("Playlist loaded, songs in queue: %d", size)
+		return nil
+	}
+
+	// Smart playlists are resolved against the library when they are queued.
+	if isSmartFile(args.Path) {
+		songs, err := loadSmartSongs(args.Path)
+		if err != nil {
+			return err
 		}
-		log.Debugf("Playlist loaded, songs in queue: %d", p.playlist.Size())
+
+		size := p.queueSongs(songs...)
+		log.Debugf("Smart playlist resolved, songs in queue: %d", size)
 		return nil
 	}
 
-	song, err := playlist.NewSong(*targetPath)
+	song, err := playlist.NewSong(args.Path)
 	if err != nil {
 		return err
 	}
 
+	size := p.queueSongs(song)
+	log.Debugf("Add song to playlist, songs in queue: %d", size)
+
+	return nil
+}
+
+// queueSongs adds the songs to the end of the playlist and returns the number of
+// songs in the queue. The first song becomes the current song if there is none.
+func (p *PlayerServer) queueSongs(songs ...*playlist.Song) int {
+	speaker.Lock()
+	p.playlist.Queue(songs...)
 	if p.currentSong == nil {
-		p.currentSong = song
+		p.currentSong = p.playlist.Head
 	}
+	size := p.playlist.Size()
+	speaker.Unlock()
 
-	p.playlist.Queue(song)
-	log.Debugf("Add song to playlist, songs in queue: %d", p.playlist.Size())
+	p.publish(protocol.EventQueue)
 
-	return nil
+	return size
 }
 
 // TrackInfo returns the metadata of the current song in the playlist.
-func (p *PlayerServer) TrackInfo(args *struct{}, prop *playlist.AudioProperties) error {
+func (p *PlayerServer) TrackInfo(args *protocol.Empty, prop *playlist.AudioProperties) error {
 	*prop = *p.currentSong.Prop
 
 	return nil
@@ -155,7 +225,7 @@ func (p *PlayerServer) TrackInfo(args *struct{}, prop *playlist.AudioProperties)
 
 // PlaylistInfo writes a formatted string containing the playlist contents,
 // including song numbers, file names, and an indicator for the currently playing song.
-func (p *PlayerServer) PlaylistInfo(args *struct{}, infoMsg *string) error {
+func (p *PlayerServer) PlaylistInfo(args *protocol.Empty, reply *protocol.PlaylistInfoReply) error {
 	var sb strings.Builder
 	numCap := int(math.Log10(float64(p.playlist.Size()))) + 1
 	for i, song := range p.playlist.ListSongs() {
@@ -167,15 +237,247 @@ func (p *PlayerServer) PlaylistInfo(args *struct{}, infoMsg *string) error {
 		sb.WriteString(fmt.Sprintf("%0*d [%s]\n", numCap, i+1, song.Prop.FileName))
 	}
 
-	*infoMsg = sb.String()
+	reply.Text = sb.String()
+	return nil
+}
+
+// Tracks returns the entries of the playback queue along with their metadata
+// and durations.
+func (p *PlayerServer) Tracks(args *protocol.Empty, reply *protocol.TracksReply) error {
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	reply.Tracks = make([]protocol.Track, 0, p.playlist.Size())
+	for song, i := p.playlist.Head, 1; song != nil; song, i = song.Next, i+1 {
+		track := protocol.Track{
+			Index:    i,
+			Current:  song == p.currentSong,
+			Path:     song.FullPath,
+			Prop:     song.Prop,
+			Duration: song.Format.SampleRate.D(song.Streamer.Len()).Round(time.Second),
+		}
+		if track.Current {
+			reply.Current = i
+		}
+		reply.Tracks = append(reply.Tracks, track)
+	}
+
+	return nil
+}
+
+// Cover returns the artwork of the current track: the picture embedded in the
+// audio file or the cover image in its directory. The reply is empty if there is none.
+func (p *PlayerServer) Cover(args *protocol.Empty, reply *protocol.CoverReply) error {
+	speaker.Lock()
+	var path string
+	if p.currentSong != nil {
+		path = p.currentSong.FullPath
+	}
+	speaker.Unlock()
+	if path == "" {
+		return nil
+	}
+
+	reply.Path = path
+	cover, err := playlist.ReadCover(path)
+	if errors.Is(err, playlist.ErrNoCover) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	reply.MIMEType, reply.Data, reply.Source = cover.MIMEType, cover.Data, cover.Source
+
+	return nil
+}
+
+// Lyrics returns the lyrics of the current track, read from the .lrc file next
+// to it or from its tags, along with the playback position. The reply has no
+// lines if there are none. The lyrics are read once per track, since clients
+// following them call the method repeatedly, and again when the .lrc file is
+// added or changed.
+func (p *PlayerServer) Lyrics(args *protocol.Empty, reply *protocol.LyricsReply) error {
+	speaker.Lock()
+	if song := p.currentSong; song != nil {
+		reply.Path = song.FullPath
+		reply.Position = song.Format.SampleRate.D(song.Streamer.Position())
+		reply.Paused = p.ctrl.Paused
+	}
+	speaker.Unlock()
+	if reply.Path == "" {
+		return nil
+	}
+
+	sidecar, modTime := lyrics.Sidecar(reply.Path)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lyricsPath != reply.Path || p.lyricsSidecar != sidecar || !p.lyricsModTime.Equal(modTime) {
+		l, err := lyrics.Load(reply.Path)
+		if err != nil && !errors.Is(err, lyrics.ErrNoLyrics) {
+			return err
+		}
+		p.lyricsPath, p.lyrics = reply.Path, l
+		p.lyricsSidecar, p.lyricsModTime = sidecar, modTime
+	}
+	if l := p.lyrics; l != nil {
+		reply.Lines, reply.Synced, reply.Source = l.Lines, l.Synced, l.Source
+	}
+
+	return nil
+}
+
+// ReloadTags reads the tags of the queued tracks with the given path again,
+// e.g. after they were edited, and updates the now-playing files if one of
+// them is the current track.
+func (p *PlayerServer) ReloadTags(args *protocol.ReloadTagsArgs, reply *protocol.ReloadTagsReply) error {
+	prop, err := playlist.NewAudioProperties(args.Path)
+	if err != nil {
+		return err
+	}
+
+	speaker.Lock()
+	var current *playlist.Song
+	for song := p.playlist.Head; song != nil; song = song.Next {
+		if song.FullPath != args.Path {
+			continue
+		}
+		song.SetProp(prop)
+		reply.Updated++
+		if song == p.currentSong {
+			current = song
+		}
+	}
+	speaker.Unlock()
+	if reply.Updated == 0 {
+		return nil
+	}
+
+	log.Debugf("Reloaded tags of %s, %d entries in queue", args.Path, reply.Updated)
+	if current != nil {
+		p.updateNowPlaying(current)
+	}
+	p.publish(protocol.EventQueue)
+
+	return nil
+}
+
+// Jump starts playing the track at the given position of the queue.
+func (p *PlayerServer) Jump(args *protocol.JumpArgs, reply *protocol.Empty) error {
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	song := p.playlist.At(args.Index)
+	if song == nil {
+		return fmt.Errorf("%w: %d", ErrInvalidIndex, args.Index)
+	}
+	p.ctrl.Paused = true
+	p.currentSong = song
+	p.ready()
+
+	return nil
+}
+
+// Remove removes the track at the given position from the queue. If it is the current
+// track, skips to the next one, or stops playback if there is none.
+func (p *PlayerServer) Remove(args *protocol.RemoveArgs, reply *protocol.Empty) error {
+	speaker.Lock()
+	song := p.playlist.At(args.Index)
+	if song == nil {
+		speaker.Unlock()
+		return fmt.Errorf("%w: %d", ErrInvalidIndex, args.Index)
+	}
+	last := p.removeSong(song)
+	speaker.Unlock()
+	if last {
+		p.shutdown()
+		return nil
+	}
+
+	log.Debugf("Remove song from playlist, songs in queue: %d", p.playlist.Size())
+	p.publish(protocol.EventQueue)
+
+	return nil
+}
+
+// removeSong removes the song from the queue. If it is the current song, playback
+// continues with the next one; it reports whether there is none, in which case the
+// player has to be stopped. The speaker must be locked.
+func (p *PlayerServer) removeSong(song *playlist.Song) bool {
+	if song != p.currentSong {
+		p.playlist.Remove(song)
+		song.Streamer.Close()
+		return false
+	}
+
+	next := song.Next
+	p.playlist.Remove(song)
+	// The paused streamer of the removed song is no longer read.
+	p.ctrl.Paused = true
+	song.Streamer.Close()
+	if next == nil {
+		return true
+	}
+	p.currentSong = next
+	p.ready()
+
+	return false
+}
+
+// closeQueue closes the streamers of the queued songs when the player stops.
+func (p *PlayerServer) closeQueue() {
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	for song := p.playlist.Head; song != nil; song = song.Next {
+		song.Streamer.Close()
+	}
+}
+
+// Move moves the track at one position of the queue to another.
+func (p *PlayerServer) Move(args *protocol.MoveArgs, reply *protocol.Empty) error {
+	speaker.Lock()
+	ok := p.playlist.Move(args.From, args.To)
+	speaker.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %d → %d", ErrInvalidIndex, args.From, args.To)
+	}
+
+	p.publish(protocol.EventQueue)
+
+	return nil
+}
+
+// Seek changes the playback position of the current track. The position is
+// limited to the last second of the track, since decoders may fail to seek to
+// the very end.
+func (p *PlayerServer) Seek(args *protocol.SeekArgs, reply *protocol.Empty) error {
+	speaker.Lock()
+	song := p.currentSong
+	if song == nil {
+		speaker.Unlock()
+		return nil
+	}
+	pos := song.Format.SampleRate.N(args.Position)
+	if args.Relative {
+		pos += song.Streamer.Position()
+	}
+	pos = max(0, min(pos, song.Streamer.Len()-song.Format.SampleRate.N(time.Second)))
+	err := song.Streamer.Seek(pos)
+	speaker.Unlock()
+	if err != nil {
+		return err
+	}
+
+	p.publish(protocol.EventSeek)
+
 	return nil
 }
 
 // Next skips to the next track. If the current track is the last one, stops playback.
-func (p *PlayerServer) Next(args *struct{}, reply *struct{}) error {
+func (p *PlayerServer) Next(args *protocol.Empty, reply *protocol.Empty) error {
 	speaker.Lock()
 	if p.currentSong.Next == nil {
-		close(p.done)
+		p.shutdown()
 	} else {
 		p.ctrl.Paused = true
 		p.currentSong = p.currentSong.Next
@@ -188,7 +490,7 @@ func (p *PlayerServer) Next(args *struct{}, reply *struct{}) error {
 
 // Rewind rewinds to the previous track. If the current track is the first one,
 // rewinds to the start of the current track.
-func (p *PlayerServer) Rewind(args *struct{}, reply *struct{}) error {
+func (p *PlayerServer) Rewind(args *protocol.Empty, reply *protocol.Empty) error {
 	speaker.Lock()
 	if p.currentSong.Prev == nil {
 		p.currentSong.Streamer.Seek(0)
@@ -207,42 +509,219 @@ func (p *PlayerServer) Rewind(args *struct{}, reply *struct{}) error {
 // If the directory does not exist, it will be created.
 // The file name will be in the format "YYYY-MM-DD_HH-MM-SS.m3u".
 // The method returns the path of the saved file through the reply parameter.
-func (p *PlayerServer) SavePlaylist(dir *string, reply *string) error {
-	if *dir == "-" {
-		homeDir, err := env.GetHomeDir()
-		if err != nil {
+func (p *PlayerServer) SavePlaylist(args *protocol.SavePlaylistArgs, reply *protocol.SavePlaylistReply) error {
+	dir := args.Dir
+	if dir == "-" {
+		p.mu.Lock()
+		playlistDir := p.playlistDir
+		p.mu.Unlock()
+
+		var err error
+		if dir, err = env.ExpandPath(playlistDir); err != nil {
 			return err
 		}
-		*dir = path.Join(homeDir, p.playlistDir)
 	} else {
-		if !env.PathExists(*dir) {
+		if !env.PathExists(dir) {
 			return env.ErrInvalidPath
 		}
 	}
 
-	err := os.Mkdir(*dir, 0755)
-	if err != nil && !errors.Is(err, os.ErrExist) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
 		return err
 	}
 
 	t := time.Now()
 	fileName := fmt.Sprintf("%d-%02d-%02d_%02d-%02d-%02d.m3u", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
-	err = m3u.Save(p.playlist, path.Join(*dir, fileName))
+	err = m3u.Save(p.playlist, path.Join(dir, fileName))
+	if err != nil {
+		return err
+	}
+
+	reply.Path = path.Join(dir, fileName)
+	return nil
+}
+
+// Status returns the current state of the player.
+func (p *PlayerServer) Status(args *protocol.Empty, reply *protocol.Status) error {
+	*reply = p.status()
+
+	return nil
+}
+
+// WaitEvent blocks until an event newer than the one specified in the arguments
+// is published, or until a timeout expires. It allows clients to subscribe to
+// the player state changes by calling it in a loop.
+func (p *PlayerServer) WaitEvent(args *protocol.WaitEventArgs, reply *protocol.Event) error {
+	*reply = p.events.wait(args.Since, eventWaitTimeout, p.done)
+
+	return nil
+}
+
+// ConfigGet returns the value of a config key used by the running player.
+func (p *PlayerServer) ConfigGet(args *protocol.ConfigGetArgs, reply *protocol.ConfigGetReply) error {
+	p.mu.Lock()
+	cfg := *p.conf
+	p.mu.Unlock()
+
+	// The volume may have been changed since the config was loaded.
+	if args.Key == volumeKey {
+		speaker.Lock()
+		cfg.VolLevel = mapVolumeToScale(p.vol.Volume)
+		speaker.Unlock()
+	}
+
+	value, err := cfg.Get(args.Key)
 	if err != nil {
 		return err
 	}
+	reply.Value = value
 
-	*reply = path.Join(*dir, fileName)
 	return nil
 }
 
+// ConfigSet changes a config key of the running player and saves it to the config file.
+func (p *PlayerServer) ConfigSet(args *protocol.ConfigSetArgs, reply *protocol.Empty) error {
+	p.mu.Lock()
+	cfg := *p.conf
+	p.mu.Unlock()
+	prevLevel := cfg.VolLevel
+
+	if err := cfg.Set(args.Key, args.Value); err != nil {
+		return err
+	}
+	if err := validateConfig(&cfg); err != nil {
+		return err
+	}
+
+	// Named instances keep their volume level in the state file, which is saved on exit.
+	if args.Key != volumeKey || instanceName == "" {
+		if err := conf.UpdateFile(p.confPath, &cfg, args.Key); err != nil {
+			return err
+		}
+	}
+
+	p.applyConfig(&cfg)
+	// The level is set even if it is unchanged, since the volume may have been
+	// changed while playing. applyConfig has set a changed one already.
+	if args.Key == volumeKey && (instanceName != "" || cfg.VolLevel == prevLevel) {
+		p.setVolumeLevel(cfg.VolLevel)
+	}
+	log.Debugf("Config key %s set to %q", args.Key, args.Value)
+
+	return nil
+}
+
+// status collects the current state of the player.
+// It must not be called while the speaker is locked.
+func (p *PlayerServer) status() protocol.Status {
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	return p.songStatus(p.currentSong)
+}
+
+// songStatus collects the state of the player playing the given song.
+// The speaker must be locked.
+func (p *PlayerServer) songStatus(song *playlist.Song) protocol.Status {
+	st := protocol.Status{QueueSize: p.playlist.Size()}
+	st.Paused = p.ctrl.Paused
+	st.Muted = p.vol.Silent
+	st.Volume = mapVolumeToScale(p.vol.Volume)
+
+	if song == nil {
+		return st
+	}
+
+	st.Playing = true
+	st.Path = song.FullPath
+	st.Track = song.Prop
+	sr := song.Format.SampleRate
+	st.Position = sr.D(song.Streamer.Position()).Round(time.Second)
+	st.Duration = sr.D(song.Streamer.Len()).Round(time.Second)
+
+	for cur := p.playlist.Head; cur != nil && cur != song; cur = cur.Next {
+		st.Index++
+	}
+	st.Index++
+
+	return st
+}
+
+// publish notifies the subscribed clients about a change of the player state.
+// It must not be called while the speaker is locked.
+func (p *PlayerServer) publish(kind string) {
+	p.events.publish(kind, p.status())
+}
+
+// applyConfig updates the settings of the running player from the configuration.
+// Settings of the audio output, such as the sample rate, take effect after restart.
+func (p *PlayerServer) applyConfig(cfg *conf.Config) {
+	p.mu.Lock()
+	prev := p.conf
+	p.conf = cfg
+	p.playlistDir = cfg.PlaylistDir
+	p.nowPlaying = newNowPlaying(cfg)
+	p.mu.Unlock()
+
+	speaker.Lock()
+	song := p.currentSong
+	speaker.Unlock()
+	p.updateNowPlaying(song)
+	p.setLibraryWatch(cfg.LibraryWatch)
+
+	// The volume changed while playing is kept, unless the config sets another
+	// one. Named instances take their volume from the state file instead.
+	if instanceName == "" && (prev == nil || prev.VolLevel != cfg.VolLevel) {
+		p.setVolumeLevel(cfg.VolLevel)
+	}
+
+	if level, ok := parseLogLevel(cfg.LogLevel); ok {
+		log.SetLevel(level)
+	}
+}
+
+// setVolumeLevel sets the volume to the level of the config scale and unmutes it.
+func (p *PlayerServer) setVolumeLevel(level float64) {
+	vol := min(max(mapScaleToVolume(level), volLimitMin), volLimitMax)
+	speaker.Lock()
+	p.vol.Volume = vol
+	p.vol.Silent = false
+	speaker.Unlock()
+	p.publish(protocol.EventVolume)
+}
+
+// updateNowPlaying writes the given song to the now-playing files.
+func (p *PlayerServer) updateNowPlaying(song *playlist.Song) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.nowPlaying.update(song); err != nil {
+		log.Errorf("Unable to write now playing file: %v", err)
+	}
+}
+
+// shutdown signals the daemon to finish by closing the `done` channel.
+// It is safe to call it more than once.
+func (p *PlayerServer) shutdown() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+	})
+}
+
 // ready signals the playlist that it should send the next song to the SongChan channel.
+// A song still waiting in the channel is replaced, so that it never blocks while
+// the speaker is locked. Callers must either lock the speaker or be the only sender.
 func (p *PlayerServer) ready() {
 	if p.currentSong != nil {
 		p.currentSong.Streamer.Seek(0)
+		select {
+		case <-p.playlist.SongChan:
+		default:
+		}
 		p.playlist.SongChan <- p.currentSong
 	} else {
-		close(p.done)
+		p.shutdown()
 	}
 }
 
@@ -251,10 +730,21 @@ func (p *PlayerServer) nextSong() chan *playlist.Song {
 	return p.playlist.SongChan
 }
 
+// config returns the configuration the player currently runs with.
+func (p *PlayerServer) config() *conf.Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.conf
+}
+
 func NewPlayerServer(playlistDir string) *PlayerServer {
 	p := PlayerServer{
 		playlist:    playlist.NewPlaylist(),
 		playlistDir: playlistDir,
+		nowPlaying:  &nowPlaying{},
+		conf:        &conf.Config{PlaylistDir: playlistDir},
+		events:      newEventBus(),
 		ctrl:        &beep.Ctrl{},
 		vol:         &effects.Volume{},
 		done:        make(chan struct{}),
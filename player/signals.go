@@ -0,0 +1,47 @@
+package player
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+
+	"scythix/protocol"
+)
+
+// notifySignals relays the signals handled by the daemon to the returned channel.
+func notifySignals() chan os.Signal {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	return sigs
+}
+
+// handleSignals controls the player server with the signals received by the daemon
+// until the server is stopped:
+//
+//   - SIGTERM and SIGINT stop playback and shut the daemon down gracefully;
+//   - SIGHUP reloads the config file;
+//   - SIGUSR1 toggles pause;
+//   - SIGUSR2 skips to the next track.
+func (p *PlayerServer) handleSignals(sigs <-chan os.Signal) {
+	for {
+		select {
+		case sig := <-sigs:
+			log.Debugf("Got signal %v", sig)
+			switch sig {
+			case syscall.SIGTERM, syscall.SIGINT:
+				p.shutdown()
+			case syscall.SIGHUP:
+				p.reloadConfig()
+			case syscall.SIGUSR1:
+				p.Pause(&protocol.Empty{}, &protocol.Empty{})
+			case syscall.SIGUSR2:
+				p.Next(&protocol.Empty{}, &protocol.Empty{})
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
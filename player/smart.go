@@ -0,0 +1,191 @@
+package player
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"scythix/conf"
+	"scythix/env"
+	"scythix/library"
+	"scythix/m3u"
+	"scythix/playlist"
+)
+
+// maxSmartTracks limits the number of tracks of a smart playlist that are queued,
+// since every queued song keeps its file open.
+const maxSmartTracks = 1000
+
+// smartPlaylistErrors returns the problems found in the queries of the smart
+// playlists of the config, ordered by name.
+func smartPlaylistErrors(cfg *conf.Config) []error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(cfg.SmartPlaylists)) {
+		if _, err := library.ParseSmart(cfg.SmartPlaylists[name]); err != nil {
+			errs = append(errs, fmt.Errorf("smart_playlists.%s: %w", name, err))
+		}
+	}
+
+	return errs
+}
+
+// isSmartFile reports whether the path is a file holding a smart query.
+func isSmartFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), library.SmartExt)
+}
+
+// smartTracks returns the tracks of the library selected by the smart query.
+func smartTracks(text string) ([]*library.Track, error) {
+	query, err := library.ParseSmart(text)
+	if err != nil {
+		return nil, err
+	}
+
+	lib, err := openLibrary()
+	if err != nil {
+		return nil, err
+	}
+	if len(lib.Tracks) == 0 {
+		return nil, library.ErrNoLibrary
+	}
+
+	return lib.Resolve(query), nil
+}
+
+// loadSmartSongs resolves the smart playlist file against the library and
+// returns the songs to queue, at most maxSmartTracks. Tracks that can't be
+// decoded are left out.
+func loadSmartSongs(path string) ([]*playlist.Song, error) {
+	text, err := library.ReadSmartFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := smartTracks(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(tracks) > maxSmartTracks {
+		log.Errorf("Smart playlist %s selects %d tracks, only the first %d are queued", path, len(tracks), maxSmartTracks)
+		tracks = tracks[:maxSmartTracks]
+	}
+
+	var songs []*playlist.Song
+	for _, t := range tracks {
+		song, err := playlist.NewSong(t.Path)
+		if err != nil {
+			log.Errorf("Failed to load song %s: %v", t.Path, err)
+			continue
+		}
+		songs = append(songs, song)
+	}
+	if len(songs) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoPlayableFiles, path)
+	}
+
+	return songs, nil
+}
+
+// smartQuery returns the name and the query of a smart playlist: a file, a playlist
+// defined in the smart_playlists table of the config, or a .smart file of that
+// name in the playlist directory.
+func smartQuery(spec string) (string, string, error) {
+	if isSmartFile(spec) {
+		text, err := library.ReadSmartFile(spec)
+		return strings.TrimSuffix(filepath.Base(spec), filepath.Ext(spec)), text, err
+	}
+
+	cfg, _, err := effectiveConfig()
+	if err != nil {
+		return "", "", err
+	}
+	if text, ok := cfg.SmartPlaylists[spec]; ok {
+		return spec, text, nil
+	}
+
+	dir, err := env.ExpandPath(cfg.PlaylistDir)
+	if err != nil {
+		return "", "", err
+	}
+	text, err := library.ReadSmartFile(filepath.Join(dir, spec+library.SmartExt))
+	if os.IsNotExist(err) {
+		return "", "", fmt.Errorf("%w: %s", ErrNoSmartPlaylist, spec)
+	}
+
+	return spec, text, err
+}
+
+// smartPaths returns the paths of the tracks of the smart playlist given with
+// the -smart flag of play and queue, at most maxSmartTracks.
+func smartPaths(spec string) ([]string, error) {
+	_, text, err := smartQuery(spec)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := smartTracks(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", spec, err)
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoMatches, spec)
+	}
+	if len(tracks) > maxSmartTracks {
+		fmt.Fprintf(os.Stderr, "scythix: smart playlist %s selects %d tracks, only the first %d are queued, use LIMIT to choose them\n",
+			spec, len(tracks), maxSmartTracks)
+		tracks = tracks[:maxSmartTracks]
+	}
+
+	paths := make([]string, 0, len(tracks))
+	for _, t := range tracks {
+		paths = append(paths, t.Path)
+	}
+
+	return paths, nil
+}
+
+// saveSmart writes the tracks the smart playlist currently selects to an M3U
+// playlist in the directory, the playlist directory if dir is "-".
+func saveSmart(spec, dir string) (string, error) {
+	name, text, err := smartQuery(spec)
+	if err != nil {
+		return "", err
+	}
+	tracks, err := smartTracks(text)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", spec, err)
+	}
+
+	if dir == "-" {
+		cfg, _, err := effectiveConfig()
+		if err != nil {
+			return "", err
+		}
+		if dir, err = env.ExpandPath(cfg.PlaylistDir); err != nil {
+			return "", err
+		}
+	} else if !env.PathExists(dir) {
+		return "", env.ErrInvalidPath
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// The songs are only written to the playlist, so their files are not decoded.
+	snapshot := playlist.NewPlaylist()
+	for _, t := range tracks {
+		prop := t.Prop
+		snapshot.Queue(&playlist.Song{FullPath: t.Path, Prop: &prop})
+	}
+
+	path := filepath.Join(dir, name+"_"+time.Now().Format("2006-01-02_15-04-05")+".m3u")
+	if err := m3u.Save(snapshot, path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
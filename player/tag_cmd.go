@@ -0,0 +1,158 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"scythix/protocol"
+	"scythix/tagedit"
+)
+
+// tagUsage describes the subcommands of the tag command.
+var tagUsage = "Display or change the tags of MP3 (ID3v2) and FLAC (Vorbis comment) files.\n\n" +
+	"  show FILE...          Display the tags\n" +
+	"  set [FLAGS] FILE...   Change the tags, an empty value removes the field\n\n" +
+	"Flags of set: --" + strings.Join(tagedit.Fields(), ", --") + "\n" +
+	"Track and disc numbers may include the total, e.g. --track 3/12."
+
+// runTag runs the show and set subcommands of the tag command.
+func runTag(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("%w: expected show FILE... or set [FLAGS] FILE...", ErrUsage)
+	}
+
+	switch args[0] {
+	case "show":
+		return showTags(args[1:])
+	case "set":
+		changes, files, err := parseTagFlags(args[1:])
+		if err != nil {
+			return err
+		}
+		return setTags(ctx, changes, files)
+	}
+
+	return fmt.Errorf("%w: unknown subcommand %q, expected show or set", ErrUsage, args[0])
+}
+
+// parseTagFlags returns the changes given with the flags of the set subcommand
+// and the files that follow them.
+func parseTagFlags(args []string) (tagedit.Tags, []string, error) {
+	fs := flag.NewFlagSet("tag set", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	for _, field := range tagedit.Fields() {
+		fs.String(field, "", "")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	// Only the flags given are changed, so that an empty value can remove a field.
+	changes := tagedit.Tags{}
+	fs.Visit(func(f *flag.Flag) { changes[f.Name] = f.Value.String() })
+	if len(changes) == 0 {
+		return nil, nil, fmt.Errorf("%w: no tags to set", ErrUsage)
+	}
+	if fs.NArg() == 0 {
+		return nil, nil, fmt.Errorf("%w: no files given", ErrUsage)
+	}
+	if err := changes.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	return changes, fs.Args(), nil
+}
+
+// showTags prints the tags of the files.
+func showTags(files []string) error {
+	var docs []tagsJSON
+	var errs []error
+	for i, file := range files {
+		tags, format, err := tagedit.Read(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+
+		if jsonOutput {
+			docs = append(docs, tagsJSON{Path: file, Format: format, Tags: tags})
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s)\n", file, format)
+		for _, field := range tagedit.Fields() {
+			if value, ok := tags[field]; ok {
+				fmt.Printf("%-12s | %s\n", field, value)
+			}
+		}
+	}
+	if jsonOutput && len(docs) > 0 {
+		if err := printJSON(docs); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// setTags writes the changes to the files. The running player reads the tags
+// of the edited files again, so that the queue shows the new values.
+func setTags(ctx context.Context, changes tagedit.Tags, files []string) error {
+	var errs []error
+	var written []string
+	for _, file := range files {
+		path, err := normalizePath(file)
+		if err == nil {
+			err = tagedit.Write(path, changes)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		written = append(written, path)
+	}
+
+	if len(written) > 0 {
+		if err := reloadTags(ctx, written); err != nil {
+			log.Errorf("Unable to update the tags in the player: %v", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// reloadTags makes the running player read the tags of the files again.
+// Nothing is done if the player is not running.
+func reloadTags(ctx context.Context, paths []string) error {
+	if daemonPID(lockFile) == 0 {
+		return nil
+	}
+	c, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if !c.HasFeature(protocol.FeatureReloadTags) {
+		log.Debug("Player server can't reload tags")
+		return nil
+	}
+
+	for _, path := range paths {
+		callCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		_, err := c.ReloadTags(callCtx, path)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
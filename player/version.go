@@ -0,0 +1,5 @@
+package player
+
+// Version is the release version of Scythix.
+// It is overridden at build time with -ldflags "-X scythix/player.Version=...".
+var Version = "dev"
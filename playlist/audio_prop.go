@@ -1,55 +1,36 @@
 package playlist
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
-	"reflect"
-	"strings"
+	"time"
 
 	"github.com/dhowden/tag"
+	"github.com/gopxl/beep"
 )
 
 // audioProperties represents metadata about an audio file.
 type AudioProperties struct {
-	FileName string
-	Title    string
-	Artist   string
-	Album    string
-	Genre    string
-	Year     int
-}
-
-// Display prints the audio properties to the console.
-func (p *AudioProperties) Display() {
-	fmt.Println(p.FileName)
-	fmt.Println(strings.Repeat("-", p.delimiterLen()))
-	fmt.Printf("Title  | %s\n", p.Title)
-	fmt.Printf("Artist | %s\n", p.Artist)
-	fmt.Printf("Album  | %s\n", p.Album)
-	fmt.Printf("Genre  | %s\n", p.Genre)
-	fmt.Printf("Year   | %d\n", p.Year)
-}
-
-// delimiterLen returns the length of the horizontal delimiter used in the Display method,
-// which is calculated as the maximum length of the string fields in the audioProperties structure.
-func (p *AudioProperties) delimiterLen() int {
-	maxLen := 0
-	rv := reflect.ValueOf(p).Elem()
-	for i := 0; i < rv.NumField(); i++ {
-		field := rv.Field(i)
-		if field.Kind() == reflect.String {
-			fl := len([]rune(field.String()))
-			if i != 0 {
-				fl += 9 // add offset for fieldname plus separator
-			}
-			if fl > maxLen {
-				maxLen = fl
-			}
-		}
-	}
+	FileName    string `json:"file_name"`
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	Album       string `json:"album"`
+	AlbumArtist string `json:"album_artist"`
+	Composer    string `json:"composer"`
+	Genre       string `json:"genre"`
+	Year        int    `json:"year"`
+	Track       int    `json:"track"`
+	TrackTotal  int    `json:"track_total"`
+	Disc        int    `json:"disc"`
+	DiscTotal   int    `json:"disc_total"`
+	Comment     string `json:"comment"`
 
-	return maxLen
+	// Properties of the audio stream, known once the file is decoded.
+	// The duration is left out of JSON documents, which give it in seconds.
+	Duration   time.Duration `json:"-"`
+	Bitrate    int           `json:"bitrate"` // average bitrate in kbit/s
+	Channels   int           `json:"channels"`
+	SampleRate int           `json:"sample_rate"`
 }
 
 func NewAudioProperties(filePath string) (*AudioProperties, error) {
@@ -75,8 +56,87 @@ func NewAudioProperties(filePath string) (*AudioProperties, error) {
 	prop.Title = m.Title()
 	prop.Artist = m.Artist()
 	prop.Album = m.Album()
+	prop.AlbumArtist = m.AlbumArtist()
+	prop.Composer = m.Composer()
 	prop.Genre = m.Genre()
 	prop.Year = m.Year()
+	prop.Track, prop.TrackTotal = m.Track()
+	prop.Disc, prop.DiscTotal = m.Disc()
+	prop.Comment = m.Comment()
 
 	return &prop, err
 }
+
+// setStream fills in the properties of the decoded audio stream: its format,
+// its length in samples and the size of its audio data, which gives the average bitrate.
+func (p *AudioProperties) setStream(format beep.Format, length int, size int64) {
+	p.Duration = format.SampleRate.D(length)
+	p.SampleRate = int(format.SampleRate)
+	p.Channels = format.NumChannels
+	if p.Duration > 0 {
+		p.Bitrate = int(float64(size*8) / p.Duration.Seconds() / 1000)
+	}
+}
+
+// Less reports whether the track p is placed before q on their release:
+// by album, disc and track number, and by file name if these are equal.
+func (p *AudioProperties) Less(q *AudioProperties) bool {
+	if p.Album != q.Album {
+		return p.Album < q.Album
+	}
+	if p.Disc != q.Disc {
+		return p.Disc < q.Disc
+	}
+	if p.Track != q.Track {
+		return p.Track < q.Track
+	}
+
+	return p.FileName < q.FileName
+}
+
+// audioSize returns the size of the audio data of the file of the given type:
+// the size of the file without its tags and other metadata, such as embedded
+// artwork. The file is read with ReadAt, so that the position of its decoder
+// is kept. It returns 0 if the size is unknown.
+func audioSize(f *os.File, fileType string) int64 {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+	size := fi.Size()
+
+	switch fileType {
+	case "mp3":
+		// An ID3v2 tag at the start, with its size given in 7-bit bytes,
+		// and an ID3v1 tag of 128 bytes at the end.
+		header := make([]byte, 10)
+		if _, err := f.ReadAt(header, 0); err == nil && string(header[:3]) == "ID3" {
+			tagSize := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+			size -= 10 + tagSize
+			if header[5]&0x10 != 0 {
+				size -= 10 // footer
+			}
+		}
+		trailer := make([]byte, 3)
+		if _, err := f.ReadAt(trailer, fi.Size()-128); err == nil && string(trailer) == "TAG" {
+			size -= 128
+		}
+	case "flac":
+		// The metadata blocks following the "fLaC" marker, each with a header
+		// holding a flag for the last block and the length of its data.
+		offset := int64(4)
+		header := make([]byte, 4)
+		for {
+			if _, err := f.ReadAt(header, offset); err != nil {
+				return 0
+			}
+			offset += 4 + (int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3]))
+			if header[0]&0x80 != 0 {
+				break
+			}
+		}
+		size -= offset
+	}
+
+	return max(size, 0)
+}
@@ -0,0 +1,105 @@
+package playlist
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dhowden/tag"
+)
+
+var ErrNoCover = fmt.Errorf("no cover art found")
+
+// coverFiles lists the names of the image files searched for in the directory
+// of a track without embedded artwork, in the order of preference.
+var coverFiles = []string{
+	"cover.jpg", "cover.jpeg", "cover.png",
+	"folder.jpg", "folder.jpeg", "folder.png",
+	"front.jpg", "front.jpeg", "front.png",
+}
+
+// Cover is the artwork of a track.
+type Cover struct {
+	MIMEType string
+	Data     []byte
+	// Source is the path of the image file, or empty if the artwork is embedded
+	// in the audio file.
+	Source string
+}
+
+// Ext returns the file name extension matching the image type, e.g. ".jpg".
+func (c *Cover) Ext() string {
+	switch c.MIMEType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	}
+	if exts, _ := mime.ExtensionsByType(c.MIMEType); len(exts) > 0 {
+		return exts[0]
+	}
+
+	return ""
+}
+
+// ReadCover returns the artwork embedded in the audio file, or the cover image
+// found in its directory, such as cover.jpg or folder.png. ErrNoCover is returned
+// if there is neither.
+func ReadCover(trackPath string) (*Cover, error) {
+	f, err := os.Open(trackPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if m, err := tag.ReadFrom(f); err == nil {
+		if pic := m.Picture(); pic != nil && len(pic.Data) > 0 {
+			mimeType := pic.MIMEType
+			if mimeType == "" || !strings.Contains(mimeType, "/") {
+				mimeType = mime.TypeByExtension("." + strings.ToLower(pic.Ext))
+			}
+			return &Cover{MIMEType: mimeType, Data: pic.Data}, nil
+		}
+	}
+
+	return readCoverFile(filepath.Dir(trackPath))
+}
+
+// readCoverFile returns the preferred cover image in the directory. The file
+// names are matched regardless of case.
+func readCoverFile(dir string) (*Cover, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	best := -1
+	var name string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		i := slices.Index(coverFiles, strings.ToLower(e.Name()))
+		if i >= 0 && (best < 0 || i < best) {
+			best, name = i, e.Name()
+		}
+	}
+	if best < 0 {
+		return nil, ErrNoCover
+	}
+
+	source := filepath.Join(dir, name)
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cover{
+		MIMEType: mime.TypeByExtension(strings.ToLower(filepath.Ext(name))),
+		Data:     data,
+		Source:   source,
+	}, nil
+}
@@ -3,12 +3,14 @@ package playlist
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/gopxl/beep"
 	"github.com/gopxl/beep/flac"
 	"github.com/gopxl/beep/mp3"
 	"github.com/h2non/filetype"
-	log "github.com/sirupsen/logrus"
 )
 
 var ErrUnsupportedFormat = fmt.Errorf("unsupported format")
@@ -37,6 +39,80 @@ func (p *Playlist) Queue(songs ...*Song) {
 	}
 }
 
+// At returns the song at the given position, starting at 1, or nil if the
+// playlist has no such position.
+func (p *Playlist) At(index int) *Song {
+	if index < 1 {
+		return nil
+	}
+	current := p.Head
+	for i := 1; current != nil && i < index; i++ {
+		current = current.Next
+	}
+
+	return current
+}
+
+// Index returns the position of the song in the playlist, starting at 1,
+// or 0 if the song is not in the playlist.
+func (p *Playlist) Index(s *Song) int {
+	i := 1
+	for current := p.Head; current != nil; current = current.Next {
+		if current == s {
+			return i
+		}
+		i++
+	}
+
+	return 0
+}
+
+// Remove unlinks the song from the playlist.
+func (p *Playlist) Remove(s *Song) {
+	if p.Index(s) == 0 {
+		return
+	}
+
+	if s.Prev != nil {
+		s.Prev.Next = s.Next
+	} else {
+		p.Head = s.Next
+	}
+	if s.Next != nil {
+		s.Next.Prev = s.Prev
+	}
+	s.Next, s.Prev = nil, nil
+	p.size--
+}
+
+// Move moves the song at position from to position to, shifting the songs in between.
+// It reports whether both positions exist.
+func (p *Playlist) Move(from, to int) bool {
+	s := p.At(from)
+	if s == nil || to < 1 || to > p.size {
+		return false
+	}
+	if from == to {
+		return true
+	}
+
+	p.Remove(s)
+	if next := p.At(to); next != nil {
+		s.Prev, s.Next = next.Prev, next
+		if next.Prev != nil {
+			next.Prev.Next = s
+		} else {
+			p.Head = s
+		}
+		next.Prev = s
+		p.size++
+	} else {
+		p.Queue(s)
+	}
+
+	return true
+}
+
 // Size returns the number of songs in the playlist.
 func (p *Playlist) Size() int {
 	return p.size
@@ -58,24 +134,33 @@ func (p *Playlist) ListSongs() []Song {
 // controls the lifetime of the playlist.
 func NewPlaylist() *Playlist {
 	p := &Playlist{}
-	p.SongChan = make(chan *Song)
+	// The channel holds the song to play next, so that sending it never waits
+	// for the player to take the previous one.
+	p.SongChan = make(chan *Song, 1)
 
 	return p
 }
 
 // getFileType takes a string representation of a path to a file and returns its extension.
-func getFileType(path string) string {
-	f, err := os.ReadFile(path)
+// Only the header of the file is read.
+func getFileType(path string) (string, error) {
+	kind, err := filetype.MatchFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
-	kind, err := filetype.Match(f)
-	if err != nil {
-		log.Fatal(err)
-	}
+	return kind.Extension, nil
+}
+
+// SupportedFormats returns the extensions of the audio file formats that can be played.
+func SupportedFormats() []string {
+	return []string{"mp3", "flac"}
+}
 
-	return kind.Extension
+// IsAudioFile reports whether the file has the extension of a supported audio format.
+func IsAudioFile(file string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))
+	return slices.Contains(SupportedFormats(), ext)
 }
 
 // streamerForType returns a StreamSeekCloser, Format, and error for the given file type.
@@ -0,0 +1,91 @@
+package playlist
+
+import (
+	"slices"
+	"testing"
+)
+
+// newTestPlaylist returns a playlist of songs named after their paths.
+func newTestPlaylist(paths ...string) *Playlist {
+	p := NewPlaylist()
+	for _, path := range paths {
+		p.Queue(&Song{FullPath: path})
+	}
+
+	return p
+}
+
+// paths returns the paths of the songs in the playlist, checking that the links
+// to the previous songs match the order.
+func paths(t *testing.T, p *Playlist) []string {
+	t.Helper()
+	var got []string
+	var prev *Song
+	for s := p.Head; s != nil; s = s.Next {
+		if s.Prev != prev {
+			t.Errorf("song %s is linked to the wrong previous song", s.FullPath)
+		}
+		got = append(got, s.FullPath)
+		prev = s
+	}
+	if len(got) != p.Size() {
+		t.Errorf("Size() = %d, want %d", p.Size(), len(got))
+	}
+
+	return got
+}
+
+func TestPlaylistMove(t *testing.T) {
+	tests := []struct {
+		from, to int
+		want     []string
+		ok       bool
+	}{
+		{1, 3, []string{"b", "c", "a", "d"}, true},
+		{3, 1, []string{"c", "a", "b", "d"}, true},
+		{1, 4, []string{"b", "c", "d", "a"}, true},
+		{4, 1, []string{"d", "a", "b", "c"}, true},
+		{2, 3, []string{"a", "c", "b", "d"}, true},
+		{3, 2, []string{"a", "c", "b", "d"}, true},
+		{2, 2, []string{"a", "b", "c", "d"}, true},
+		{0, 2, []string{"a", "b", "c", "d"}, false},
+		{5, 2, []string{"a", "b", "c", "d"}, false},
+		{2, 0, []string{"a", "b", "c", "d"}, false},
+		{2, 5, []string{"a", "b", "c", "d"}, false},
+	}
+
+	for _, tt := range tests {
+		p := newTestPlaylist("a", "b", "c", "d")
+		if ok := p.Move(tt.from, tt.to); ok != tt.ok {
+			t.Errorf("Move(%d, %d) = %v, want %v", tt.from, tt.to, ok, tt.ok)
+		}
+		if got := paths(t, p); !slices.Equal(got, tt.want) {
+			t.Errorf("Move(%d, %d): playlist = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestPlaylistRemove(t *testing.T) {
+	tests := []struct {
+		index int
+		want  []string
+	}{
+		{1, []string{"b", "c"}},
+		{2, []string{"a", "c"}},
+		{3, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		p := newTestPlaylist("a", "b", "c")
+		s := p.At(tt.index)
+		p.Remove(s)
+		if got := paths(t, p); !slices.Equal(got, tt.want) {
+			t.Errorf("Remove(%d): playlist = %v, want %v", tt.index, got, tt.want)
+		}
+		// Removing a song which is not in the playlist has no effect.
+		p.Remove(s)
+		if got := paths(t, p); !slices.Equal(got, tt.want) {
+			t.Errorf("Remove(%d) twice: playlist = %v, want %v", tt.index, got, tt.want)
+		}
+	}
+}
@@ -17,6 +17,17 @@ type Song struct {
 	Prev     *Song
 }
 
+// SetProp replaces the metadata of the song, e.g. after its tags were edited.
+// The properties of the audio stream are kept.
+func (s *Song) SetProp(prop *AudioProperties) {
+	updated := *prop
+	if s.Prop != nil {
+		updated.Duration, updated.Bitrate = s.Prop.Duration, s.Prop.Bitrate
+		updated.Channels, updated.SampleRate = s.Prop.Channels, s.Prop.SampleRate
+	}
+	s.Prop = &updated
+}
+
 func NewSong(songPath string) (*Song, error) {
 	var song Song
 	f, err := os.Open(songPath)
@@ -32,7 +43,11 @@ func NewSong(songPath string) (*Song, error) {
 	}
 
 	song.FullPath = songPath
-	fileType := getFileType(songPath)
+	fileType, err := getFileType(songPath)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
 	song.Streamer, song.Format, err = streamerForType(fileType, f)
 	if err != nil {
 		// The song file should only be closed if an error occurs.
@@ -41,5 +56,7 @@ func NewSong(songPath string) (*Song, error) {
 		return nil, ErrUnsupportedFormat
 	}
 
+	song.Prop.setStream(song.Format, song.Streamer.Len(), audioSize(f, fileType))
+
 	return &song, nil
 }
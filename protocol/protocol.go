@@ -0,0 +1,264 @@
+// Package protocol defines the request and response types exchanged between
+// the Scythix daemon and its clients over the control socket.
+//
+// The types are encoded with encoding/gob by net/rpc. Any incompatible change
+// to a method or type must be accompanied by an increment of Version.
+package protocol
+
+import (
+	"fmt"
+	"time"
+
+	"scythix/lyrics"
+	"scythix/playlist"
+)
+
+// Version is the revision of the control protocol described by this package.
+const Version = 1
+
+// Names of the RPC methods exported by the daemon.
+const (
+	MethodHello        = "PlayerServer.Hello"
+	MethodPause        = "PlayerServer.Pause"
+	MethodStop         = "PlayerServer.Stop"
+	MethodNext         = "PlayerServer.Next"
+	MethodRewind       = "PlayerServer.Rewind"
+	MethodMute         = "PlayerServer.Mute"
+	MethodTurnUp       = "PlayerServer.TurnUp"
+	MethodTurnDown     = "PlayerServer.TurnDown"
+	MethodSetVol       = "PlayerServer.SetVol"
+	MethodQueue        = "PlayerServer.Queue"
+	MethodTrackInfo    = "PlayerServer.TrackInfo"
+	MethodPlaylistInfo = "PlayerServer.PlaylistInfo"
+	MethodSavePlaylist = "PlayerServer.SavePlaylist"
+	MethodStatus       = "PlayerServer.Status"
+	MethodWaitEvent    = "PlayerServer.WaitEvent"
+	MethodConfigGet    = "PlayerServer.ConfigGet"
+	MethodConfigSet    = "PlayerServer.ConfigSet"
+	MethodTracks       = "PlayerServer.Tracks"
+	MethodJump         = "PlayerServer.Jump"
+	MethodRemove       = "PlayerServer.Remove"
+	MethodMove         = "PlayerServer.Move"
+	MethodSeek         = "PlayerServer.Seek"
+	MethodCover        = "PlayerServer.Cover"
+	MethodLyrics       = "PlayerServer.Lyrics"
+	MethodReloadTags   = "PlayerServer.ReloadTags"
+)
+
+// Kinds of events published by the daemon.
+const (
+	EventTrack  = "track"
+	EventPause  = "pause"
+	EventVolume = "volume"
+	EventQueue  = "queue"
+	EventSeek   = "seek"
+	EventStop   = "stop"
+)
+
+// Features that may be enabled in the daemon and reported by the Hello method.
+const (
+	FeatureStatus     = "status"
+	FeatureSubscribe  = "subscribe"
+	FeatureNowPlaying = "now-playing"
+	FeatureConfig     = "config"
+	FeatureTracks     = "tracks"
+	FeatureQueueEdit  = "queue-edit"
+	FeatureSeek       = "seek"
+	FeatureCover      = "cover"
+	FeatureLyrics     = "lyrics"
+	FeatureReloadTags = "reload-tags"
+)
+
+// Empty is used for requests and responses that carry no data.
+type Empty struct{}
+
+// HelloArgs is the request of the Hello method.
+type HelloArgs struct {
+	// ProtocolVersion is the protocol version spoken by the client.
+	ProtocolVersion int
+}
+
+// HelloReply is the response of the Hello method. It describes the daemon and
+// its capabilities, and must be checked by clients before issuing commands.
+type HelloReply struct {
+	// Version is the release version of the daemon executable.
+	Version string
+	// ProtocolVersion is the protocol version spoken by the daemon.
+	ProtocolVersion int
+	// PID is the process ID of the daemon.
+	PID int
+	// Formats lists the supported audio file formats, e.g. "mp3".
+	Formats []string
+	// Features lists the enabled Feature* capabilities.
+	Features []string
+}
+
+// QueueArgs is the request of the Queue method.
+type QueueArgs struct {
+	// Path is an absolute path to an audio file or an M3U playlist.
+	Path string
+}
+
+// SetVolArgs is the request of the SetVol method.
+type SetVolArgs struct {
+	// Level is the volume on the user facing scale starting at 0.
+	Level int
+}
+
+// VolumeReply is the response of the methods changing the volume level.
+type VolumeReply struct {
+	// Level is the resulting volume on the user facing scale starting at 0.
+	Level float64
+}
+
+// PlaylistInfoReply is the response of the PlaylistInfo method.
+type PlaylistInfoReply struct {
+	Text string
+}
+
+// SavePlaylistArgs is the request of the SavePlaylist method.
+type SavePlaylistArgs struct {
+	// Dir is the directory the playlist is saved to. "-" stands for the
+	// playlist directory from the daemon's configuration.
+	Dir string
+}
+
+// SavePlaylistReply is the response of the SavePlaylist method.
+type SavePlaylistReply struct {
+	Path string
+}
+
+// ConfigGetArgs is the request of the ConfigGet method.
+type ConfigGetArgs struct {
+	Key string
+}
+
+// ConfigGetReply is the response of the ConfigGet method.
+type ConfigGetReply struct {
+	Value string
+}
+
+// ConfigSetArgs is the request of the ConfigSet method.
+type ConfigSetArgs struct {
+	Key   string
+	Value string
+}
+
+// Status describes the state of the player.
+type Status struct {
+	Playing   bool
+	Paused    bool
+	Muted     bool
+	Volume    float64
+	Index     int
+	QueueSize int
+	Path      string
+	Track     *playlist.AudioProperties
+	Position  time.Duration
+	Duration  time.Duration
+}
+
+// Track describes an entry of the playback queue.
+type Track struct {
+	// Index is the position of the track in the queue, starting at 1.
+	Index    int
+	Current  bool
+	Path     string
+	Prop     *playlist.AudioProperties
+	Duration time.Duration
+}
+
+// TracksReply is the response of the Tracks method.
+type TracksReply struct {
+	// Current is the index of the current track, or 0 if nothing is playing.
+	Current int
+	Tracks  []Track
+}
+
+// JumpArgs is the request of the Jump method.
+type JumpArgs struct {
+	// Index is the position of the track to play, starting at 1.
+	Index int
+}
+
+// RemoveArgs is the request of the Remove method.
+type RemoveArgs struct {
+	// Index is the position of the track to remove, starting at 1.
+	Index int
+}
+
+// MoveArgs is the request of the Move method.
+type MoveArgs struct {
+	// From and To are positions in the queue, starting at 1.
+	From int
+	To   int
+}
+
+// SeekArgs is the request of the Seek method.
+type SeekArgs struct {
+	// Position is the position to seek to, or the offset from the current
+	// position if Relative is set.
+	Position time.Duration
+	Relative bool
+}
+
+// Errors reported by clients for replies without content.
+var (
+	ErrNoCover  = fmt.Errorf("no cover art found")
+	ErrNoLyrics = fmt.Errorf("no lyrics found")
+)
+
+// CoverReply is the response of the Cover method. Data is empty if nothing is
+// playing or the current track has no cover art.
+type CoverReply struct {
+	// Path is the path of the current track.
+	Path     string
+	MIMEType string
+	Data     []byte
+	// Source is the path of the image file, or empty if the artwork is
+	// embedded in the audio file.
+	Source string
+}
+
+// LyricsReply is the response of the Lyrics method. Lines is empty if nothing
+// is playing or the current track has no lyrics.
+type LyricsReply struct {
+	// Path is the path of the current track.
+	Path   string
+	Lines  []lyrics.Line
+	Synced bool
+	// Source is the path of the .lrc file or the name of the tag the lyrics were read from.
+	Source string
+	// Position is the exact playback position, which synchronized lyrics are followed by.
+	Position time.Duration
+	Paused   bool
+}
+
+// ReloadTagsArgs is the request of the ReloadTags method.
+type ReloadTagsArgs struct {
+	// Path is the absolute path of the audio file whose tags have changed.
+	Path string
+}
+
+// ReloadTagsReply is the response of the ReloadTags method.
+type ReloadTagsReply struct {
+	// Updated is the number of queue entries of the file.
+	Updated int
+}
+
+// WaitEventArgs is the request of the WaitEvent method.
+type WaitEventArgs struct {
+	// Since is the sequence number of the last event seen by the client.
+	// The call returns as soon as an event with a greater number is published.
+	Since uint64
+}
+
+// Event is a notification about a change of the player state.
+type Event struct {
+	// Seq is the sequence number of the event.
+	Seq uint64
+	// Kind is one of the Event* constants. It is empty if no event has been
+	// published while the request was waiting.
+	Kind   string
+	Status Status
+}
@@ -0,0 +1,226 @@
+package tagedit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const flacMagic = "fLaC"
+
+// Types of FLAC metadata blocks.
+const (
+	flacStreamInfo    = 0
+	flacPadding       = 1
+	flacVorbisComment = 4
+)
+
+// flacPaddingSize is the size of the padding block written after the metadata.
+const flacPaddingSize = 1024
+
+// flacLastBlock marks the last metadata block in its header.
+const flacLastBlock = 0x80
+
+// vorbisKeys maps the fields to the names of the Vorbis comments holding them.
+// Track and disc numbers are split into the number and the total.
+var vorbisKeys = map[string]string{
+	Title:       "TITLE",
+	Artist:      "ARTIST",
+	Album:       "ALBUM",
+	AlbumArtist: "ALBUMARTIST",
+	Composer:    "COMPOSER",
+	Genre:       "GENRE",
+	Year:        "DATE",
+	Track:       "TRACKNUMBER",
+	Disc:        "DISCNUMBER",
+	Comment:     "COMMENT",
+}
+
+// vorbisTotalKeys maps the track and disc fields to the comments holding their totals.
+var vorbisTotalKeys = map[string]string{
+	Track: "TRACKTOTAL",
+	Disc:  "DISCTOTAL",
+}
+
+// vorbisAliases maps fields to other comments read as the field by some programs,
+// which are removed when the field is changed.
+var vorbisAliases = map[string][]string{
+	Year:    {"YEAR"},
+	Comment: {"DESCRIPTION"},
+}
+
+// flacBlock is a metadata block of a FLAC file.
+type flacBlock struct {
+	kind byte
+	data []byte
+}
+
+// rewriteFLAC copies the FLAC stream with the changes applied to its Vorbis comment
+// block, which is created if missing. The padding blocks are merged into one.
+func rewriteFLAC(r *bufio.Reader, w io.Writer, changes Tags) error {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != flacMagic {
+		return ErrUnsupportedFile
+	}
+
+	var blocks []flacBlock
+	comment := -1 // index of the Vorbis comment block
+	for last := false; !last; {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("%w: truncated FLAC metadata", ErrUnsupportedTag)
+		}
+		last = header[0]&flacLastBlock != 0
+		b := flacBlock{kind: header[0] &^ flacLastBlock}
+		b.data = make([]byte, int(header[1])<<16|int(header[2])<<8|int(header[3]))
+		if _, err := io.ReadFull(r, b.data); err != nil {
+			return fmt.Errorf("%w: truncated FLAC metadata", ErrUnsupportedTag)
+		}
+
+		switch b.kind {
+		case flacPadding:
+		case flacVorbisComment:
+			if comment < 0 {
+				comment = len(blocks)
+				blocks = append(blocks, b)
+			}
+		default:
+			blocks = append(blocks, b)
+		}
+	}
+	if len(blocks) == 0 || blocks[0].kind != flacStreamInfo {
+		return fmt.Errorf("%w: missing FLAC stream info", ErrUnsupportedTag)
+	}
+
+	vendor, comments := "scythix", []string(nil)
+	if comment >= 0 {
+		var err error
+		if vendor, comments, err = parseVorbisComment(blocks[comment].data); err != nil {
+			return err
+		}
+	}
+	data := encodeVorbisComment(vendor, applyVorbisChanges(comments, changes))
+	if comment >= 0 {
+		blocks[comment].data = data
+	} else {
+		// The comment block is placed right after the stream info.
+		blocks = append(blocks[:1], append([]flacBlock{{kind: flacVorbisComment, data: data}}, blocks[1:]...)...)
+	}
+	blocks = append(blocks, flacBlock{kind: flacPadding, data: make([]byte, flacPaddingSize)})
+
+	if _, err := io.WriteString(w, flacMagic); err != nil {
+		return err
+	}
+	for i, b := range blocks {
+		if len(b.data) >= 1<<24 {
+			return fmt.Errorf("%w: metadata block too large", ErrUnsupportedTag)
+		}
+		kind := b.kind
+		if i == len(blocks)-1 {
+			kind |= flacLastBlock
+		}
+		header := []byte{kind, byte(len(b.data) >> 16), byte(len(b.data) >> 8), byte(len(b.data))}
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		if _, err := w.Write(b.data); err != nil {
+			return err
+		}
+	}
+	_, err := io.Copy(w, r)
+
+	return err
+}
+
+// parseVorbisComment returns the vendor string and the "NAME=value" comments
+// of a Vorbis comment block.
+func parseVorbisComment(data []byte) (string, []string, error) {
+	next := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return "", false
+		}
+		s := string(data[4 : 4+n])
+		data = data[4+n:]
+		return s, true
+	}
+
+	vendor, ok := next()
+	if !ok || len(data) < 4 {
+		return "", nil, fmt.Errorf("%w: malformed Vorbis comment", ErrUnsupportedTag)
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	var comments []string
+	for range count {
+		c, ok := next()
+		if !ok {
+			return "", nil, fmt.Errorf("%w: malformed Vorbis comment", ErrUnsupportedTag)
+		}
+		comments = append(comments, c)
+	}
+
+	return vendor, comments, nil
+}
+
+// encodeVorbisComment returns the Vorbis comment block with the vendor string and comments.
+func encodeVorbisComment(vendor string, comments []string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+	data = append(data, vendor...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(comments)))
+	for _, c := range comments {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(c)))
+		data = append(data, c...)
+	}
+
+	return data
+}
+
+// applyVorbisChanges replaces the comments of the changed fields. Comment names
+// are matched regardless of case.
+func applyVorbisChanges(comments []string, changes Tags) []string {
+	set := func(key, value string) {
+		kept := comments[:0:0]
+		for _, c := range comments {
+			name, _, _ := strings.Cut(c, "=")
+			if !strings.EqualFold(name, key) {
+				kept = append(kept, c)
+			}
+		}
+		if value != "" {
+			kept = append(kept, key+"="+value)
+		}
+		comments = kept
+	}
+
+	for _, field := range Fields() {
+		value, ok := changes[field]
+		if !ok {
+			continue
+		}
+		for _, alias := range vorbisAliases[field] {
+			set(alias, "")
+		}
+		totalKey, split := vorbisTotalKeys[field]
+		if !split {
+			set(vorbisKeys[field], value)
+			continue
+		}
+
+		// A total given with the number replaces the stored one, a removed
+		// number removes the total as well.
+		n, total, _ := splitNumber(value)
+		set(vorbisKeys[field], n)
+		if total != "" || value == "" {
+			set(totalKey, total)
+		}
+	}
+
+	return comments
+}
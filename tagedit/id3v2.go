@@ -0,0 +1,265 @@
+package tagedit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"scythix/id3"
+)
+
+const id3Magic = "ID3"
+
+// Flags of the ID3v2 tag header.
+const (
+	id3FlagUnsync    = 0x80
+	id3FlagExtHeader = 0x40
+	id3FlagFooter    = 0x10
+)
+
+// id3Padding is the size of the padding written after the frames, which lets
+// other taggers grow the tag without rewriting the file.
+const id3Padding = 1024
+
+// id3Frames maps the fields to the IDs of the frames holding them in ID3v2.3 and ID3v2.4.
+var id3Frames = map[string][2]string{
+	Title:       {"TIT2", "TIT2"},
+	Artist:      {"TPE1", "TPE1"},
+	Album:       {"TALB", "TALB"},
+	AlbumArtist: {"TPE2", "TPE2"},
+	Composer:    {"TCOM", "TCOM"},
+	Genre:       {"TCON", "TCON"},
+	Year:        {"TYER", "TDRC"},
+	Track:       {"TRCK", "TRCK"},
+	Disc:        {"TPOS", "TPOS"},
+	Comment:     {"COMM", "COMM"},
+}
+
+// id3Frame is a frame of an ID3v2 tag, kept as it was read unless it is replaced.
+type id3Frame struct {
+	id    string
+	flags [2]byte
+	body  []byte
+}
+
+// rewriteID3v2 copies the MP3 stream with the changes applied to its ID3v2 tag.
+// A file without a tag gets an ID3v2.3 tag. ID3v2.2 tags and unsynchronized tags
+// are not supported.
+func rewriteID3v2(r *bufio.Reader, w io.Writer, changes Tags) error {
+	version := byte(3)
+	var frames []id3Frame
+
+	if head, err := r.Peek(10); err == nil && string(head[:3]) == id3Magic {
+		var err error
+		version, frames, err = readID3v2(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	frames = applyID3Changes(frames, version, changes)
+
+	var body bytes.Buffer
+	for _, f := range frames {
+		body.WriteString(f.id)
+		size := uint32(len(f.body))
+		if version == 4 {
+			size = syncsafe(size)
+		}
+		binary.Write(&body, binary.BigEndian, size)
+		body.Write(f.flags[:])
+		body.Write(f.body)
+	}
+	body.Write(make([]byte, id3Padding))
+
+	header := []byte{'I', 'D', '3', version, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[6:], syncsafe(uint32(body.Len())))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := body.WriteTo(w); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, r)
+
+	return err
+}
+
+// readID3v2 reads the ID3v2 tag at the start of the stream and returns its major
+// version and its frames. The stream is left at the first byte after the tag.
+func readID3v2(r *bufio.Reader) (byte, []id3Frame, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	version, flags := header[3], header[5]
+	if version != 3 && version != 4 {
+		return 0, nil, fmt.Errorf("%w: ID3v2.%d", ErrUnsupportedTag, version)
+	}
+	if flags&id3FlagUnsync != 0 {
+		return 0, nil, fmt.Errorf("%w: unsynchronized ID3v2 tag", ErrUnsupportedTag)
+	}
+
+	tag := make([]byte, unsyncsafe(binary.BigEndian.Uint32(header[6:])))
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return 0, nil, err
+	}
+	if flags&id3FlagFooter != 0 {
+		if _, err := r.Discard(10); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	// The extended header is dropped, it only holds optional information
+	// such as a CRC of the frames, which is no longer valid.
+	if flags&id3FlagExtHeader != 0 {
+		if len(tag) < 4 {
+			return 0, nil, fmt.Errorf("%w: truncated extended header", ErrUnsupportedTag)
+		}
+		size := int(binary.BigEndian.Uint32(tag))
+		if version == 4 {
+			size = int(unsyncsafe(uint32(size)))
+		} else {
+			size += 4
+		}
+		tag = tag[min(size, len(tag)):]
+	}
+
+	var frames []id3Frame
+	for len(tag) >= 10 && tag[0] != 0 {
+		size := binary.BigEndian.Uint32(tag[4:])
+		if version == 4 {
+			size = unsyncsafe(size)
+		}
+		if int(size) > len(tag)-10 {
+			return 0, nil, fmt.Errorf("%w: frame %s exceeds the tag", ErrUnsupportedTag, tag[:4])
+		}
+		frames = append(frames, id3Frame{
+			id:    string(tag[:4]),
+			flags: [2]byte{tag[8], tag[9]},
+			body:  tag[10 : 10+size],
+		})
+		tag = tag[10+size:]
+	}
+
+	return version, frames, nil
+}
+
+// applyID3Changes replaces the frames of the changed fields. The new frames are
+// placed first, since readers such as dhowden/tag use the first frame of a kind.
+func applyID3Changes(frames []id3Frame, version byte, changes Tags) []id3Frame {
+	var added []id3Frame
+	for _, field := range Fields() {
+		value, ok := changes[field]
+		if !ok {
+			continue
+		}
+
+		ids := id3Frames[field]
+		id := ids[version-3]
+		// Both frames of the year are removed, so that no outdated value remains.
+		frames = deleteFrames(frames, func(f id3Frame) bool {
+			if field == Comment {
+				return f.id == id && isPlainComment(f.body)
+			}
+			return f.id == ids[0] || f.id == ids[1]
+		})
+		if value == "" {
+			continue
+		}
+
+		if field == Comment {
+			added = append(added, id3Frame{id: id, body: commentFrame(value, version)})
+			continue
+		}
+		added = append(added, id3Frame{id: id, body: encodeText(value, version)})
+	}
+
+	return append(added, frames...)
+}
+
+func deleteFrames(frames []id3Frame, del func(id3Frame) bool) []id3Frame {
+	kept := frames[:0:0]
+	for _, f := range frames {
+		if !del(f) {
+			kept = append(kept, f)
+		}
+	}
+
+	return kept
+}
+
+// isPlainComment reports whether the COMM frame body has an empty description,
+// as opposed to comments of other programs, e.g. iTunNORM.
+func isPlainComment(body []byte) bool {
+	if len(body) < 5 {
+		return true
+	}
+	desc := body[4:]
+	switch body[0] {
+	case id3.EncodingISO88591, id3.EncodingUTF8:
+		return desc[0] == 0
+	default:
+		// UTF-16 with an optional byte order mark.
+		if len(desc) >= 2 && (desc[0] == 0xff && desc[1] == 0xfe || desc[0] == 0xfe && desc[1] == 0xff) {
+			desc = desc[2:]
+		}
+		return len(desc) < 2 || desc[0] == 0 && desc[1] == 0
+	}
+}
+
+// encodeText returns the body of a text frame. ID3v2.4 tags use UTF-8, ID3v2.3
+// tags use ISO-8859-1 if possible and UTF-16 otherwise.
+func encodeText(text string, version byte) []byte {
+	if version == 4 {
+		return append([]byte{id3.EncodingUTF8}, text...)
+	}
+
+	latin1 := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 0xff {
+			return append([]byte{id3.EncodingUTF16}, utf16LE(text)...)
+		}
+		latin1 = append(latin1, byte(r))
+	}
+
+	return append([]byte{id3.EncodingISO88591}, latin1...)
+}
+
+// commentFrame returns the body of a COMM frame with an empty description.
+func commentFrame(text string, version byte) []byte {
+	enc := encodeText(text, version)
+	body := []byte{enc[0], 'e', 'n', 'g'}
+	if enc[0] == id3.EncodingUTF16 {
+		body = append(body, utf16LE("")...)
+		body = append(body, 0, 0)
+	} else {
+		body = append(body, 0)
+	}
+
+	return append(body, enc[1:]...)
+}
+
+// utf16LE encodes the text as little-endian UTF-16 preceded by a byte order mark.
+func utf16LE(text string) []byte {
+	b := []byte{0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(text)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+
+	return b
+}
+
+// syncsafe encodes the number as a synchsafe integer, which has the most
+// significant bit of every byte cleared.
+func syncsafe(n uint32) uint32 {
+	return n&0x7f | (n>>7&0x7f)<<8 | (n>>14&0x7f)<<16 | (n>>21&0x7f)<<24
+}
+
+// unsyncsafe decodes a synchsafe integer.
+func unsyncsafe(n uint32) uint32 {
+	return n&0x7f | (n>>8&0x7f)<<7 | (n>>16&0x7f)<<14 | (n>>24&0x7f)<<21
+}
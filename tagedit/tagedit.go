@@ -0,0 +1,187 @@
+// Package tagedit changes the metadata tags of audio files: ID3v2 tags of MP3
+// files and Vorbis comments of FLAC files. The files are rewritten atomically,
+// so that an interrupted write can't damage them.
+package tagedit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+
+	"scythix/env"
+)
+
+var (
+	ErrUnsupportedFile = fmt.Errorf("unsupported file, only MP3 and FLAC files can be tagged")
+	ErrUnsupportedTag  = fmt.Errorf("unsupported tag")
+	ErrInvalidField    = fmt.Errorf("invalid tag field")
+	ErrInvalidValue    = fmt.Errorf("invalid tag value")
+)
+
+// Names of the fields that can be changed.
+const (
+	Title       = "title"
+	Artist      = "artist"
+	Album       = "album"
+	AlbumArtist = "album-artist"
+	Composer    = "composer"
+	Genre       = "genre"
+	Year        = "year"
+	Track       = "track"
+	Disc        = "disc"
+	Comment     = "comment"
+)
+
+// Fields returns the names of the fields that can be changed, in display order.
+func Fields() []string {
+	return []string{Title, Artist, Album, AlbumArtist, Composer, Genre, Year, Track, Disc, Comment}
+}
+
+// Tags maps the names of fields to their values. When the tags are written,
+// an empty value removes the field and missing fields are left unchanged.
+type Tags map[string]string
+
+// Validate checks the field names and the values of the numeric fields: the year
+// is a number, the track and the disc are a number optionally followed by the
+// total, e.g. "3/12".
+func (t Tags) Validate() error {
+	for field, value := range t {
+		if !slices.Contains(Fields(), field) {
+			return fmt.Errorf("%w: %s", ErrInvalidField, field)
+		}
+		if value == "" {
+			continue
+		}
+		switch field {
+		case Year:
+			if _, err := strconv.Atoi(value); err != nil {
+				return fmt.Errorf("%w: %s must be a number, got %q", ErrInvalidValue, field, value)
+			}
+		case Track, Disc:
+			if _, _, err := splitNumber(value); err != nil {
+				return fmt.Errorf("%w: %s must be a number or N/TOTAL, got %q", ErrInvalidValue, field, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitNumber splits a track or disc number of the form "N" or "N/TOTAL".
+// The total is empty if not given.
+func splitNumber(value string) (string, string, error) {
+	n, total, found := strings.Cut(value, "/")
+	if _, err := strconv.Atoi(n); err != nil {
+		return "", "", err
+	}
+	if found {
+		if _, err := strconv.Atoi(total); err != nil {
+			return "", "", err
+		}
+	}
+
+	return n, total, nil
+}
+
+// Read returns the fields of the tags of the audio file and the name of the tag
+// format, e.g. "ID3v2.3" or "VORBIS". Empty fields are left out.
+func Read(path string) (Tags, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	m, err := tag.ReadFrom(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tags := Tags{
+		Title:       m.Title(),
+		Artist:      m.Artist(),
+		Album:       m.Album(),
+		AlbumArtist: m.AlbumArtist(),
+		Composer:    m.Composer(),
+		Genre:       m.Genre(),
+		Year:        formatNumber(m.Year(), 0),
+		Track:       formatNumber(m.Track()),
+		Disc:        formatNumber(m.Disc()),
+		Comment:     m.Comment(),
+	}
+	maps.DeleteFunc(tags, func(_, value string) bool { return value == "" })
+
+	return tags, string(m.Format()), nil
+}
+
+// formatNumber formats a number and its total, e.g. "3/12". Unknown numbers are zero.
+func formatNumber(n, total int) string {
+	switch {
+	case n == 0:
+		return ""
+	case total == 0:
+		return strconv.Itoa(n)
+	}
+
+	return fmt.Sprintf("%d/%d", n, total)
+}
+
+// Write applies the changes to the tags of the audio file. Fields that are not
+// changed and other metadata, such as pictures, are kept.
+func Write(path string, changes Tags) error {
+	if err := changes.Validate(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return ErrUnsupportedFile
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var rewrite func(r *bufio.Reader, w io.Writer) error
+	switch {
+	case bytes.Equal(magic, []byte(flacMagic)):
+		rewrite = func(r *bufio.Reader, w io.Writer) error { return rewriteFLAC(r, w, changes) }
+	case bytes.HasPrefix(magic, []byte(id3Magic)) || strings.EqualFold(filepath.Ext(path), ".mp3"):
+		rewrite = func(r *bufio.Reader, w io.Writer) error { return rewriteID3v2(r, w, changes) }
+	default:
+		return ErrUnsupportedFile
+	}
+
+	return replaceFile(path, f, rewrite)
+}
+
+// replaceFile writes the contents produced by rewrite from the file to a temporary
+// file in the same directory, which then replaces the original file.
+func replaceFile(path string, f *os.File, rewrite func(r *bufio.Reader, w io.Writer) error) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return env.ReplaceFile(path, fi.Mode().Perm(), func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		if err := rewrite(bufio.NewReader(f), bw); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+}
@@ -0,0 +1,234 @@
+package tagedit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dhowden/tag"
+)
+
+// audio stands in for the audio data following the metadata, which must be kept.
+var audio = bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x64}, 64)
+
+// id3Tag returns an ID3v2 tag of the given version with the frames. An extended
+// header is written if ext is set.
+func id3Tag(version byte, ext bool, frames ...id3Frame) []byte {
+	var body bytes.Buffer
+	flags := byte(0)
+	if ext {
+		flags |= id3FlagExtHeader
+		if version == 4 {
+			// Size including itself, one byte of flags and no flags set.
+			body.Write([]byte{0, 0, 0, 6, 1, 0})
+		} else {
+			// Size excluding itself, flags and the size of the padding.
+			body.Write([]byte{0, 0, 0, 6, 0, 0, 0, 0, 0, 0})
+		}
+	}
+	for _, f := range frames {
+		body.WriteString(f.id)
+		size := uint32(len(f.body))
+		if version == 4 {
+			size = syncsafe(size)
+		}
+		binary.Write(&body, binary.BigEndian, size)
+		body.Write(f.flags[:])
+		body.Write(f.body)
+	}
+
+	header := []byte{'I', 'D', '3', version, 0, flags, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[6:], syncsafe(uint32(body.Len())))
+
+	return append(header, body.Bytes()...)
+}
+
+// flacFile returns a FLAC stream with a stream info block, the Vorbis comments,
+// if any, and a padding block.
+func flacFile(comments ...string) []byte {
+	b := []byte(flacMagic)
+	block := func(kind byte, data []byte) {
+		b = append(b, kind, byte(len(data)>>16), byte(len(data)>>8), byte(len(data)))
+		b = append(b, data...)
+	}
+	block(flacStreamInfo, make([]byte, 34))
+	if comments != nil {
+		block(flacVorbisComment, encodeVorbisComment("test", comments))
+	}
+	block(flacPadding|flacLastBlock, make([]byte, 16))
+
+	return append(b, audio...)
+}
+
+// writeTemp writes the file contents to a temporary file with the given name.
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		changes Tags
+		want    Tags
+		format  tag.Format
+	}{
+		{
+			name: "ID3v2.3",
+			file: "song.mp3",
+			data: id3Tag(3, false,
+				id3Frame{id: "TIT2", body: encodeText("Old Title", 3)},
+				id3Frame{id: "TPE1", body: encodeText("Artist", 3)},
+				id3Frame{id: "COMM", body: commentFrame("Old comment", 3)},
+			),
+			changes: Tags{Title: "Новое название", Track: "3/12", Year: "1999", Comment: "New comment"},
+			want: Tags{Title: "Новое название", Artist: "Artist", Track: "3/12", Year: "1999",
+				Comment: "New comment"},
+			format: tag.ID3v2_3,
+		},
+		{
+			name: "ID3v2.4 with extended header",
+			file: "song.mp3",
+			data: id3Tag(4, true,
+				id3Frame{id: "TIT2", body: encodeText("Title", 4)},
+				id3Frame{id: "TDRC", body: encodeText("2001", 4)},
+				id3Frame{id: "TPE1", body: encodeText("Old Artist", 4)},
+			),
+			changes: Tags{Artist: "Ärtist", Year: "", Disc: "2"},
+			want:    Tags{Title: "Title", Artist: "Ärtist", Disc: "2"},
+			format:  tag.ID3v2_4,
+		},
+		{
+			name:    "ID3v2.3 with extended header",
+			file:    "song.mp3",
+			data:    id3Tag(3, true, id3Frame{id: "TIT2", body: encodeText("Title", 3)}),
+			changes: Tags{Album: "Album"},
+			want:    Tags{Title: "Title", Album: "Album"},
+			format:  tag.ID3v2_3,
+		},
+		{
+			name:    "MP3 without tag",
+			file:    "song.mp3",
+			changes: Tags{Title: "Title"},
+			want:    Tags{Title: "Title"},
+			format:  tag.ID3v2_3,
+		},
+		{
+			name:    "FLAC with comments",
+			file:    "song.flac",
+			data:    flacFile("TITLE=Old Title", "YEAR=1990", "TRACKNUMBER=1", "TRACKTOTAL=9", "GENRE=Jazz"),
+			changes: Tags{Title: "Title", Year: "2020", Track: "4"},
+			want:    Tags{Title: "Title", Year: "2020", Track: "4/9", Genre: "Jazz"},
+			format:  tag.VORBIS,
+		},
+		{
+			name: "FLAC without comments",
+			file: "song.flac",
+			data: flacFile(),
+			// dhowden/tag reads the artist as the composer if there is none.
+			changes: Tags{Artist: "Artist", Composer: "Composer", Disc: "1/2"},
+			want:    Tags{Artist: "Artist", Composer: "Composer", Disc: "1/2"},
+			format:  tag.VORBIS,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if !strings.HasSuffix(tt.file, ".flac") {
+				data = append(bytes.Clone(data), audio...)
+			}
+			path := writeTemp(t, tt.file, data)
+
+			if err := Write(path, tt.changes); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			got, format, err := Read(path)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+			if format != string(tt.format) {
+				t.Errorf("Read() format = %s, want %s", format, tt.format)
+			}
+
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasSuffix(b, audio) {
+				t.Error("audio data was not kept")
+			}
+		})
+	}
+}
+
+func TestWriteKeepsOtherComments(t *testing.T) {
+	itunes := append([]byte{0, 'e', 'n', 'g'}, "iTunNORM\x00 0000"...)
+	path := writeTemp(t, "song.mp3", append(id3Tag(3, false,
+		id3Frame{id: "COMM", body: itunes},
+		id3Frame{id: "COMM", body: commentFrame("Old", 3)},
+	), audio...))
+
+	if err := Write(path, Tags{Comment: "New"}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, frames, err := readID3v2(bufio.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var comments []string
+	for _, f := range frames {
+		if f.id == "COMM" {
+			comments = append(comments, string(f.body[4:]))
+		}
+	}
+	want := []string{"\x00New", "iTunNORM\x00 0000"}
+	if !slices.Equal(comments, want) {
+		t.Errorf("comments = %q, want %q", comments, want)
+	}
+}
+
+func TestTagsValidate(t *testing.T) {
+	tests := []struct {
+		tags    Tags
+		wantErr error
+	}{
+		{Tags{Title: "Title", Year: "2001", Track: "3/12", Disc: "1"}, nil},
+		{Tags{Year: "", Track: ""}, nil},
+		{Tags{"lyrics": "text"}, ErrInvalidField},
+		{Tags{Year: "MMI"}, ErrInvalidValue},
+		{Tags{Track: "3/"}, ErrInvalidValue},
+		{Tags{Disc: "one"}, ErrInvalidValue},
+	}
+
+	for _, tt := range tests {
+		if err := tt.tags.Validate(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%v.Validate() error = %v, want %v", tt.tags, err, tt.wantErr)
+		}
+	}
+}
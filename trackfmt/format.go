@@ -0,0 +1,165 @@
+package trackfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"scythix/playlist"
+	"scythix/protocol"
+)
+
+var ErrInvalidFormat = fmt.Errorf("invalid format")
+
+// Format is a display template for the output of the player commands. The template
+// either contains %key% placeholders, e.g. "%artist% - %title% [%elapsed%/%duration%]",
+// or uses the Go template syntax with the same keys, e.g. "{{.artist}} - {{.title}}".
+// A literal percent sign is written as %%.
+type Format struct {
+	text string
+	tmpl *template.Template
+}
+
+// Parse compiles the format template. Templates containing "{{" are parsed
+// as Go templates.
+func Parse(text string) (*Format, error) {
+	f := &Format{text: text}
+	if strings.Contains(text, "{{") {
+		tmpl, err := template.New("format").Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+		}
+		f.tmpl = tmpl
+	}
+
+	return f, nil
+}
+
+// Execute renders the template with the given fields. Unknown %key% placeholders
+// are left untouched, unknown keys of Go templates render as empty strings.
+func (f *Format) Execute(fields map[string]string) (string, error) {
+	if f.tmpl == nil {
+		return expandPercent(f.text, fields), nil
+	}
+
+	var sb strings.Builder
+	if err := f.tmpl.Execute(&sb, fields); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// expandPercent substitutes %key% placeholders in text with values from fields.
+func expandPercent(text string, fields map[string]string) string {
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(text, '%')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start+1:], '%')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+
+		sb.WriteString(text[:start])
+		key := text[start+1 : end]
+		if val, ok := fields[key]; ok {
+			sb.WriteString(val)
+			text = text[end+1:]
+			continue
+		}
+		if key == "" {
+			sb.WriteByte('%')
+			text = text[end+1:]
+			continue
+		}
+		// Not a placeholder, the closing percent sign may open the next one.
+		sb.WriteString(text[start:end])
+		text = text[end:]
+	}
+	sb.WriteString(text)
+
+	return sb.String()
+}
+
+// Duration formats the duration as minutes and seconds, e.g. "03:07".
+func Duration(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// Name returns the name of a track for display from its fields, "artist – title",
+// with the file name standing in for a missing title.
+func Name(fields map[string]string) string {
+	name := fields["title"]
+	if name == "" {
+		name = fields["filename"]
+	}
+	if fields["artist"] != "" {
+		name = fields["artist"] + " – " + name
+	}
+
+	return name
+}
+
+// TrackFields returns the placeholders available for an entry of the playback queue:
+// the fields of its audio properties, index, marker ("►" for the current track),
+// path and duration. The index is padded with zeros to the given width.
+func TrackFields(t protocol.Track, width int) map[string]string {
+	fields := propFields(t.Prop)
+	fields["index"] = fmt.Sprintf("%0*d", width, t.Index)
+	fields["marker"] = " "
+	if t.Current {
+		fields["marker"] = "►"
+	}
+	fields["path"] = t.Path
+	fields["duration"] = duration(t.Duration)
+
+	return fields
+}
+
+// StatusFields returns the placeholders available for the player state: the fields
+// of the current track, state, elapsed, remaining, duration, volume, index and queue_size.
+// The remaining time and the duration are empty if the duration is unknown.
+func StatusFields(st *protocol.Status) map[string]string {
+	fields := propFields(st.Track)
+
+	state := "Stopped"
+	switch {
+	case st.Paused:
+		state = "Paused"
+	case st.Playing:
+		state = "Playing"
+	}
+	fields["state"] = state
+
+	vol := strconv.FormatFloat(st.Volume, 'g', -1, 64)
+	if st.Muted {
+		vol = "muted"
+	}
+	fields["volume"] = vol
+	fields["path"] = st.Path
+	fields["elapsed"] = Duration(st.Position)
+	fields["remaining"] = ""
+	if st.Duration != 0 {
+		fields["remaining"] = Duration(st.Duration - st.Position)
+	}
+	fields["duration"] = duration(st.Duration)
+	fields["index"] = strconv.Itoa(st.Index)
+	fields["queue_size"] = strconv.Itoa(st.QueueSize)
+
+	return fields
+}
+
+// propFields returns the fields of the audio properties, which may be missing.
+func propFields(prop *playlist.AudioProperties) map[string]string {
+	if prop == nil {
+		prop = &playlist.AudioProperties{}
+	}
+	return Fields(prop)
+}
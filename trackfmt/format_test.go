@@ -0,0 +1,89 @@
+package trackfmt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"scythix/playlist"
+	"scythix/protocol"
+)
+
+func TestParse(t *testing.T) {
+	fields := map[string]string{"artist": "Artist", "title": "Title", "elapsed": "01:02"}
+
+	tests := []struct {
+		text    string
+		want    string
+		wantErr error
+	}{
+		{text: "%artist% - %title%", want: "Artist - Title"},
+		{text: "%title% [%elapsed%]", want: "Title [01:02]"},
+		{text: "100%% %title%", want: "100% Title"},
+		{text: "%unknown% %title%", want: "%unknown% Title"},
+		{text: "50% %title%", want: "50% Title"},
+		{text: "%title", want: "%title"},
+		{text: "", want: ""},
+		{text: "{{.artist}} – {{.title}}", want: "Artist – Title"},
+		{text: "{{.missing}}|{{.title}}", want: "|Title"},
+		{text: "{{if .artist}}{{.artist}}{{end}}", want: "Artist"},
+		{text: "{{.title", wantErr: ErrInvalidFormat},
+		{text: "{{end}}", wantErr: ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		f, err := Parse(tt.text)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		got, err := f.Execute(fields)
+		if err != nil {
+			t.Errorf("Parse(%q).Execute() error = %v", tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q).Execute() = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		prop *playlist.AudioProperties
+		want string
+	}{
+		{&playlist.AudioProperties{FileName: "a.mp3", Title: "Title", Artist: "Artist"}, "Artist – Title"},
+		{&playlist.AudioProperties{FileName: "a.mp3", Title: "Title"}, "Title"},
+		{&playlist.AudioProperties{FileName: "a.mp3", Artist: "Artist"}, "Artist – a.mp3"},
+		{&playlist.AudioProperties{FileName: "a.mp3"}, "a.mp3"},
+	}
+
+	for _, tt := range tests {
+		if got := Name(Fields(tt.prop)); got != tt.want {
+			t.Errorf("Name(%+v) = %q, want %q", *tt.prop, got, tt.want)
+		}
+	}
+}
+
+func TestStatusFields(t *testing.T) {
+	tests := []struct {
+		st                  protocol.Status
+		duration, remaining string
+	}{
+		{protocol.Status{Playing: true, Position: 62 * time.Second, Duration: 187 * time.Second}, "03:07", "02:05"},
+		{protocol.Status{Playing: true, Position: 62 * time.Second}, "", ""},
+		{protocol.Status{}, "", ""},
+	}
+
+	for _, tt := range tests {
+		fields := StatusFields(&tt.st)
+		if fields["duration"] != tt.duration || fields["remaining"] != tt.remaining {
+			t.Errorf("StatusFields(%+v) duration, remaining = %q, %q, want %q, %q",
+				tt.st, fields["duration"], fields["remaining"], tt.duration, tt.remaining)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package trackfmt renders audio file metadata through user-defined templates,
+// such as "%artist% – %title%".
+package trackfmt
+
+import (
+	"strconv"
+	"time"
+
+	"scythix/playlist"
+)
+
+// Fields returns the template placeholders available for the given audio
+// properties, mapped to their values. Numbers that are unknown are empty.
+func Fields(prop *playlist.AudioProperties) map[string]string {
+	return map[string]string{
+		"filename":     prop.FileName,
+		"title":        prop.Title,
+		"artist":       prop.Artist,
+		"album":        prop.Album,
+		"album_artist": prop.AlbumArtist,
+		"composer":     prop.Composer,
+		"genre":        prop.Genre,
+		"comment":      prop.Comment,
+		"year":         number(prop.Year),
+		"track":        number(prop.Track),
+		"track_total":  number(prop.TrackTotal),
+		"disc":         number(prop.Disc),
+		"disc_total":   number(prop.DiscTotal),
+		"duration":     duration(prop.Duration),
+		"bitrate":      number(prop.Bitrate),
+		"channels":     number(prop.Channels),
+		"sample_rate":  number(prop.SampleRate),
+	}
+}
+
+// number formats a numeric property, which is zero if unknown.
+func number(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// duration formats a duration property, which is zero if unknown.
+func duration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return Duration(d)
+}
@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// Names of the keys that don't produce a printable character.
+const (
+	keyUp       = "up"
+	keyDown     = "down"
+	keyLeft     = "left"
+	keyRight    = "right"
+	keyHome     = "home"
+	keyEnd      = "end"
+	keyPageUp   = "pgup"
+	keyPageDown = "pgdn"
+	keyDelete   = "delete"
+	keyEnter    = "enter"
+	keyEscape   = "esc"
+	keyCtrlC    = "ctrl-c"
+)
+
+// escapeKeys maps the escape sequences sent by terminals to key names.
+var escapeKeys = map[string]string{
+	"\x1b[A":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1b[C":  keyRight,
+	"\x1b[D":  keyLeft,
+	"\x1bOA":  keyUp,
+	"\x1bOB":  keyDown,
+	"\x1bOC":  keyRight,
+	"\x1bOD":  keyLeft,
+	"\x1b[H":  keyHome,
+	"\x1b[F":  keyEnd,
+	"\x1b[1~": keyHome,
+	"\x1b[4~": keyEnd,
+	"\x1b[3~": keyDelete,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+}
+
+// readKeys reads key presses from the terminal and sends their names to the channel:
+// the names above, or the typed character. It returns when reading fails.
+func readKeys(r io.Reader, keys chan<- string) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// parseKeys splits the input read from the terminal into key presses.
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			key, n := parseEscape(b)
+			keys = append(keys, key)
+			b = b[n:]
+			continue
+		}
+
+		switch b[0] {
+		case '\r', '\n':
+			keys = append(keys, keyEnter)
+		case 0x03:
+			keys = append(keys, keyCtrlC)
+		case 0x7f:
+			keys = append(keys, keyDelete)
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[n:]
+			continue
+		}
+		b = b[1:]
+	}
+
+	return keys
+}
+
+// parseEscape returns the key of the escape sequence at the start of b and its length.
+// A lone escape character is reported as the escape key.
+func parseEscape(b []byte) (string, int) {
+	if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+		return keyEscape, 1
+	}
+
+	// The sequence ends with a letter or a tilde.
+	end := 2
+	for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+		end++
+	}
+	if end == len(b) {
+		return keyEscape, len(b)
+	}
+	seq := string(b[:end+1])
+	if key, ok := escapeKeys[seq]; ok {
+		return key, end + 1
+	}
+
+	return "", end + 1
+}
@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var ErrNotTerminal = fmt.Errorf("not a terminal")
+
+// terminal switches a terminal into raw mode, so that key presses are read
+// one at a time and not echoed, and restores its previous settings.
+type terminal struct {
+	fd    int
+	saved syscall.Termios
+}
+
+// winsize is the terminal size reported by the TIOCGWINSZ ioctl.
+type winsize struct {
+	rows, cols, xpixel, ypixel uint16
+}
+
+// makeRaw puts the terminal with the given file descriptor into raw mode.
+func makeRaw(fd int) (*terminal, error) {
+	t := &terminal{fd: fd}
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&t.saved)); err != nil {
+		return nil, ErrNotTerminal
+	}
+
+	raw := t.saved
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, fmt.Errorf("unable to set terminal mode: %w", err)
+	}
+
+	return t, nil
+}
+
+// restore brings back the terminal settings saved by makeRaw.
+func (t *terminal) restore() error {
+	return ioctl(t.fd, syscall.TCSETS, unsafe.Pointer(&t.saved))
+}
+
+// size returns the number of columns and rows of the terminal.
+func (t *terminal) size() (int, int, error) {
+	var ws winsize
+	if err := ioctl(t.fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+
+	return int(ws.cols), int(ws.rows), nil
+}
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,387 @@
+// Package tui implements the interactive full-screen terminal interface,
+// which controls a running Scythix daemon over its control socket.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"scythix/client"
+	"scythix/protocol"
+	"scythix/trackfmt"
+)
+
+var ErrUnsupported = fmt.Errorf("the player server doesn't support the terminal interface, restart it with the current version")
+
+const (
+	// refreshInterval is the interval the progress of the current track is updated at.
+	refreshInterval = time.Second
+	// callTimeout limits the time a single call to the daemon may take.
+	callTimeout = 5 * time.Second
+	// seekStep is the offset the position is changed by with the arrow keys.
+	seekStep = 5 * time.Second
+	// volumeMax is the highest level of the user facing volume scale.
+	volumeMax = 24
+)
+
+// Lines taken by the parts of the screen other than the queue.
+const (
+	headerLines = 8
+	footerLines = 1
+)
+
+const help = "space pause  n/p next/prev  ←/→ seek  +/- vol  m mute  enter play  J/K move  d remove  q quit"
+
+// ui holds the state of the terminal interface.
+type ui struct {
+	c     *client.Client
+	title string
+
+	status  *protocol.Status
+	tracks  []protocol.Track
+	cursor  int // position of the selected track in tracks
+	offset  int // position of the first visible track
+	message string
+
+	width, height int
+}
+
+// Run shows the terminal interface on the standard output and handles key presses
+// until the user quits or the player stops. The title is shown in the top bar,
+// e.g. the name of the player instance.
+func Run(ctx context.Context, c *client.Client, title string) error {
+	for _, feature := range []string{protocol.FeatureStatus, protocol.FeatureTracks} {
+		if !c.HasFeature(feature) {
+			return ErrUnsupported
+		}
+	}
+
+	term, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.restore()
+
+	out := bufio.NewWriter(os.Stdout)
+	// Switch to the alternate screen and hide the cursor.
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	out.Flush()
+	defer func() {
+		fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	// Events of the daemon trigger a refresh, so that changes made by other
+	// clients are shown immediately.
+	events := make(chan string, 1)
+	stopped := make(chan struct{})
+	if c.HasFeature(protocol.FeatureSubscribe) {
+		go func() {
+			c.Subscribe(ctx, func(ev protocol.Event) error {
+				select {
+				case events <- ev.Kind:
+				default:
+				}
+				return nil
+			})
+			close(stopped)
+		}()
+	}
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	u := &ui{c: c, title: title, cursor: -1}
+	for {
+		u.width, u.height, err = term.size()
+		if err != nil {
+			return err
+		}
+		if err := u.refresh(ctx); err != nil {
+			return err
+		}
+		u.draw(out)
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			u.message = ""
+			if quit := u.handleKey(ctx, key); quit {
+				return nil
+			}
+		case <-events:
+		case <-stopped:
+			return nil
+		case <-winch:
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh fetches the player state and the queue from the daemon.
+func (u *ui) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	st, err := u.c.Status(ctx)
+	if err != nil {
+		return err
+	}
+	reply, err := u.c.Tracks(ctx)
+	if err != nil {
+		return err
+	}
+	u.status, u.tracks = st, reply.Tracks
+
+	// The current track is selected when the interface is opened.
+	if u.cursor < 0 {
+		u.cursor = max(reply.Current-1, 0)
+	}
+	u.cursor = max(0, min(u.cursor, len(u.tracks)-1))
+
+	return nil
+}
+
+// handleKey executes the action bound to the key. It reports whether the user quits.
+func (u *ui) handleKey(ctx context.Context, key string) bool {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	var err error
+	switch key {
+	case "q", keyCtrlC, keyEscape:
+		return true
+	case " ":
+		err = u.c.Pause(ctx)
+	case "n":
+		err = u.c.Next(ctx)
+	case "p", "b":
+		err = u.c.Rewind(ctx)
+	case keyLeft, keyRight:
+		offset := seekStep
+		if key == keyLeft {
+			offset = -seekStep
+		}
+		err = u.require(protocol.FeatureSeek, func() error { return u.c.Seek(ctx, offset, true) })
+	case "+", "=":
+		_, err = u.c.TurnUp(ctx)
+	case "-", "_":
+		_, err = u.c.TurnDown(ctx)
+	case "m":
+		err = u.c.Mute(ctx)
+	case keyUp, "k":
+		u.cursor--
+	case keyDown, "j":
+		u.cursor++
+	case keyPageUp:
+		u.cursor -= u.queueRows()
+	case keyPageDown:
+		u.cursor += u.queueRows()
+	case keyHome, "g":
+		u.cursor = 0
+	case keyEnd, "G":
+		u.cursor = len(u.tracks) - 1
+	case keyEnter:
+		err = u.require(protocol.FeatureQueueEdit, func() error { return u.c.Jump(ctx, u.cursor+1) })
+	case "K", "J":
+		to := u.cursor + 2
+		if key == "K" {
+			to = u.cursor
+		}
+		if to < 1 || to > len(u.tracks) {
+			break
+		}
+		err = u.require(protocol.FeatureQueueEdit, func() error { return u.c.Move(ctx, u.cursor+1, to) })
+		if err == nil {
+			u.cursor = to - 1
+		}
+	case "d", keyDelete:
+		if len(u.tracks) > 0 {
+			err = u.require(protocol.FeatureQueueEdit, func() error { return u.c.Remove(ctx, u.cursor+1) })
+		}
+	}
+	if err != nil {
+		u.message = err.Error()
+	}
+
+	return false
+}
+
+// require calls fn if the daemon supports the feature.
+func (u *ui) require(feature string, fn func() error) error {
+	if !u.c.HasFeature(feature) {
+		return fmt.Errorf("%q is not supported by the player server", feature)
+	}
+	return fn()
+}
+
+// queueRows returns the number of queue entries that fit on the screen.
+func (u *ui) queueRows() int {
+	return max(u.height-headerLines-footerLines, 1)
+}
+
+// draw renders the whole screen.
+func (u *ui) draw(out *bufio.Writer) {
+	st := u.status
+	lines := make([]string, 0, u.height)
+
+	right := fmt.Sprintf("%d/%d ", st.Index, st.QueueSize)
+	left := " Scythix"
+	if u.title != "" {
+		left += " ─ " + u.title
+	}
+	lines = append(lines, reverse(fit(left, u.width-runeLen(right))+right))
+	lines = append(lines, "")
+
+	fields := trackfmt.StatusFields(st)
+	symbol := "■"
+	switch {
+	case st.Paused:
+		symbol = "‖"
+	case st.Playing:
+		symbol = "▶"
+	}
+	name := trackfmt.Name(fields)
+	lines = append(lines, bold(fit(fmt.Sprintf(" %s %s", symbol, name), u.width)))
+	album := fields["album"]
+	if fields["year"] != "" {
+		album += " (" + fields["year"] + ")"
+	}
+	lines = append(lines, fit("   "+album, u.width))
+
+	times := fmt.Sprintf(" %s / %s", fields["elapsed"], fields["duration"])
+	lines = append(lines, "   "+progressBar(st.Position, st.Duration, u.width-3-runeLen(times)-1)+times)
+
+	lines = append(lines, fit("   "+u.indicators(), u.width))
+	lines = append(lines, "")
+	lines = append(lines, bold(fit(fmt.Sprintf(" Queue (%d)", len(u.tracks)), u.width)))
+
+	rows := u.queueRows()
+	if u.cursor < u.offset {
+		u.offset = u.cursor
+	} else if u.cursor >= u.offset+rows {
+		u.offset = u.cursor - rows + 1
+	}
+	u.offset = max(0, min(u.offset, len(u.tracks)-rows))
+
+	width := len(fmt.Sprint(len(u.tracks)))
+	for i := u.offset; i < len(u.tracks) && i < u.offset+rows; i++ {
+		line := u.trackLine(u.tracks[i], width)
+		if i == u.cursor {
+			line = reverse(line)
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < u.height-footerLines {
+		lines = append(lines, "")
+	}
+
+	footer := help
+	if u.message != "" {
+		footer = u.message
+	}
+	lines = append(lines, dim(fit(" "+footer, u.width)))
+
+	if len(lines) > u.height {
+		lines = lines[:u.height]
+	}
+	fmt.Fprint(out, "\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			fmt.Fprint(out, "\r\n")
+		}
+		fmt.Fprint(out, line, "\x1b[K")
+	}
+	fmt.Fprint(out, "\x1b[J")
+	out.Flush()
+}
+
+// indicators returns the volume level and the playback modes.
+func (u *ui) indicators() string {
+	st := u.status
+	level := int(st.Volume)
+	bar := strings.Repeat("▮", max(0, min(level, volumeMax))) + strings.Repeat("▯", max(0, volumeMax-level))
+	modes := []string{fmt.Sprintf("vol %s %g", bar, st.Volume)}
+	if st.Muted {
+		modes = append(modes, "[muted]")
+	}
+	if st.Paused {
+		modes = append(modes, "[paused]")
+	}
+
+	return strings.Join(modes, "  ")
+}
+
+// trackLine renders an entry of the queue.
+func (u *ui) trackLine(t protocol.Track, width int) string {
+	fields := trackfmt.TrackFields(t, width)
+	name := trackfmt.Name(fields)
+
+	prefix := fmt.Sprintf(" %s %s  ", fields["marker"], fields["index"])
+	suffix := "  " + fields["duration"] + " "
+	return prefix + fit(name, u.width-runeLen(prefix)-runeLen(suffix)) + suffix
+}
+
+// progressBar renders the position within the duration as a bar of the given width.
+func progressBar(pos, dur time.Duration, width int) string {
+	if width < 2 {
+		return ""
+	}
+	filled := 0
+	if dur > 0 {
+		filled = int(int64(width) * int64(pos) / int64(dur))
+	}
+	filled = max(0, min(filled, width))
+
+	return strings.Repeat("━", filled) + strings.Repeat("─", width-filled)
+}
+
+// fit truncates or pads the text with spaces to the given number of characters.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) > width {
+		if width == 1 {
+			return "…"
+		}
+		return string(r[:width-1]) + "…"
+	}
+
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
+
+func reverse(s string) string {
+	return "\x1b[7m" + s + "\x1b[0m"
+}
+
+func bold(s string) string {
+	return "\x1b[1m" + s + "\x1b[0m"
+}
+
+func dim(s string) string {
+	return "\x1b[2m" + s + "\x1b[0m"
+}